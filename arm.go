@@ -25,15 +25,26 @@ type Arm struct {
 	acc     float64 //angular acceleration of the arm in radians/second^2
 	moi     float64 //moment of inertia of the arm
 	voltage float64 //current voltage being output
+	current float64 //current through each motor in Amps
 
-	numMotors float64 //number of motors powering the arm
-	kT        float64 //torque constant of the arm
+	numMotors  float64 //number of motors powering the arm
+	kT         float64 //torque constant of the arm
+	loadTorque float64 //torque on the joint from the links further along the chain
 
-	pid   pidcontroller //PID controller for the arm
-	motor Motor         //motor controlling the arm
+	ff ffGains //optional feedforward terms for the arm
+
+	pid      pidcontroller //PID controller for the arm
+	velPID   pidcontroller //PID controller for velocity mode, output per radian/second
+	curPID   pidcontroller //PID controller for current mode, output per Amp
+	schedule *gainSchedule //table to look up the PID gains from, nil for fixed gains
+	motor    Motor         //motor controlling the arm
 
 	stopped bool //whether the arm is stopped or not
 
+	limited  bool    //whether the arm has joint limits
+	minAngle float64 //lowest angle the joint can reach in radians
+	maxAngle float64 //highest angle the joint can reach in radians
+
 	isSecondJoint bool    //whether the arm is the second joint or not
 	parentAngle   float64 //angle of the base joint if the arm is the second joint
 
@@ -144,6 +155,46 @@ func (a *Arm) setAngle(newAngle float64) {
 	a.angle = newAngle
 } //end setAngle
 
+//Set the limits of the joint
+//float64 min - lowest angle the joint can reach in radians
+//float64 max - highest angle the joint can reach in radians
+func (a *Arm) setLimits(min, max float64) {
+	a.limited = true
+	a.minAngle = min
+	a.maxAngle = max
+} //end setLimits
+
+//Check if an angle is within the limits of the joint
+//float64 angle - angle of the joint in radians
+func (a Arm) withinLimits(angle float64) bool {
+	return !a.limited || (angle >= a.minAngle && angle <= a.maxAngle)
+} //end withinLimits
+
+//Clamp an angle to the limits of the joint
+//float64 angle - angle of the joint in radians
+//return - the closest angle within the limits
+func (a Arm) clampToLimits(angle float64) float64 {
+	if !a.limited {
+		return angle
+	} //if
+	return math.Max(a.minAngle, math.Min(a.maxAngle, angle))
+} //end clampToLimits
+
+//Calculate the voltage produced by the motors spinning
+//float64 vel - angular velocity of the joint in radians/second
+//return - the back-EMF in Volts
+func (a Arm) calcBackEMF(vel float64) float64 {
+	return (vel * a.gearRatio) / a.motor.kV
+} //end calcBackEMF
+
+//Calculate the current drawn by each motor
+//float64 voltage - voltage applied to the motors
+//float64 vel - angular velocity of the joint in radians/second
+//return - current through each motor in Amps
+func (a Arm) calcCurrent(voltage, vel float64) float64 {
+	return (voltage - a.calcBackEMF(vel)) / a.motor.kResistance
+} //end calcCurrent
+
 //PHYSICS
 
 //Calculate the torque caused on the arm by gravity
@@ -154,15 +205,25 @@ func (a Arm) calcGravTorque() float64 {
 //Calculate the current acceleration of the arm
 //float64 output - output voltage to drive the arm
 func (a *Arm) calcAccel(output float64) {
+	a.acc = a.calcAccelAt(a.parentAngle+a.angle, a.vel, output, a.loadTorque)
+} //end calcAccel
+
+//Calculate the acceleration of the arm at any state without changing the arm
+//float64 theta - angle of the arm from the horizontal in radians
+//float64 vel - angular velocity of the arm in radians/second
+//float64 output - output voltage to drive the arm
+//float64 load - torque on the joint from the links further along the chain
+//return - angular acceleration of the arm in radians/second^2
+func (a Arm) calcAccelAt(theta, vel, output, load float64) float64 {
 	voltConst := (a.gearRatio * a.kT) / (a.motor.kResistance * a.moi)                           //proportional to voltage
 	velConst := (a.kT * a.gearRatio * a.gearRatio) / (a.motor.kV * a.motor.kResistance * a.moi) //proportional to velocity
 
 	//gravity acceleration
-	theta := a.parentAngle + a.angle
 	gravAcc := (3 * math.Cos(theta) * g) / (2 * a.length) //simplified torque / moment of inertia equation
+	loadAcc := load / a.moi                               //load from the links further along the chain
 
-	a.acc = output*voltConst - a.vel*velConst - gravAcc //sum of all contributions
-} //end calcAccel
+	return output*voltConst - vel*velConst - gravAcc - loadAcc //sum of all contributions
+} //end calcAccelAt
 
 //MOTION
 
@@ -194,8 +255,17 @@ func (a *Arm) movePID(setpoint, current, epsilon float64) {
 //float64 current - current angle of the arm
 //float64 epsilon - tolerance for the angle in radians
 func (a *Arm) movePIDFF(setpoint, current, epsilon float64) {
+	a.movePIDWithFF(setpoint, current, epsilon, calcFFArm(a))
+} //end movePIDFF
+
+//drive the arm using PID control with a given feedforward voltage
+//float64 setpoint - goal angle to move to
+//float64 current - current angle of the arm
+//float64 epsilon - tolerance for the angle in radians
+//float64 ff - feedforward voltage added to the PID output
+func (a *Arm) movePIDWithFF(setpoint, current, epsilon, ff float64) {
 	//calculate voltage based on the PID output (full PID output + feedforward = maxVoltage)
-	a.voltage = MaxVoltage*OutputClamp(a.pid.calcPID(setpoint, current, epsilon), -1, 1) + calcFFArm(a)
+	a.voltage = MaxVoltage*OutputClamp(a.pid.calcPID(setpoint, current, epsilon), -1, 1) + ff
 
 	a.update() //update the arm
 
@@ -204,7 +274,14 @@ func (a *Arm) movePIDFF(setpoint, current, epsilon float64) {
 	} else {
 		a.stopped = false //must be set to false in order for multiple commands to work
 	} //if
-} //end movePIDFF
+} //end movePIDWithFF
+
+//drive the arm with a voltage calculated by another controller
+//float64 voltage - voltage to apply to the arm
+func (a *Arm) moveVoltage(voltage float64) {
+	a.voltage = voltage
+	a.update() //update the arm
+} //end moveVoltage
 
 //move the arm to the line formed by a goal point and origin (single-joint IK)
 //Point goal - (x,y) point in meters
@@ -219,6 +296,7 @@ func (a *Arm) pointToGoal(goal Point, tolerance float64) {
 //update the coordinates of the endpoint based on the angle
 func (a *Arm) update() {
 	a.voltage = OutputClamp(a.voltage, -12, 12) //clamp the voltage to min and max
+	a.current = a.calcCurrent(a.voltage, a.vel) //current drawn at this voltage
 
 	//update acceleration, velocity and position
 	a.calcAccel(a.voltage)
@@ -12,9 +12,15 @@ import (
 
 //Arm2 is a two degree of freedom arm is made of two Arm structs, with the elbow dynamically changing start position
 type Arm2 struct {
-	arm1  *Arm       //the base joint (shoulder)
-	arm2  *Arm       //the second joint (elbow)
-	timer time.Timer //timer to delay arm tracking goal points
+	arm1    *Arm       //the base joint (shoulder)
+	arm2    *Arm       //the second joint (elbow)
+	arm3    *Arm       //the third joint (wrist) holding the claw, nil for two links
+	timer   time.Timer //timer to delay arm tracking goal points
+	payload float64    //mass held at the end of the second joint in kg
+
+	extForce  Point           //force pushed on the end point from outside in N
+	surface   *contactSurface //surface the end point can press against, nil for none
+	obstacles []obstacle      //obstacles IK keeps the arm clear of when it can
 } //end struct
 
 //Updates the position of the arms, translating the second joint start to the first joint end
@@ -22,6 +28,19 @@ func (a2 *Arm2) update() {
 	a2.arm2.setStartPt(a2.arm1.getEndPtPxl())
 	a2.arm2.parentAngle = a2.arm1.angle
 	a2.arm1.parentAngle = 0
+	a2.arm1.loadTorque = a2.calcDistalTorque(a2.arm1.angle, a2.arm2.angle)
+	a2.arm2.loadTorque = a2.calcPayloadTorque(a2.arm1.angle, a2.arm2.angle)
+
+	//outside forces push the joints along, against the load
+	ext1, ext2 := a2.forceToTorques(a2.arm1.angle, a2.arm2.angle, a2.calcExternalForce())
+	a2.arm1.loadTorque -= ext1
+	a2.arm2.loadTorque -= ext2
+
+	//the wrist hangs off the end of the elbow
+	if a2.arm3 != nil {
+		a2.arm3.setStartPt(a2.arm2.get2JEndPtPxl(a2.arm1.angle))
+		a2.arm3.parentAngle = a2.arm1.angle + a2.arm2.angle
+	} //if
 } //end update
 
 //updates the individual arms with zero voltage
@@ -38,38 +57,45 @@ func (a2 Arm2) rest() {
 func (a2 *Arm2) setArmColors(color [3]int) {
 	a2.arm1.color = color
 	a2.arm2.color = color
+	if a2.arm3 != nil {
+		a2.arm3.color = color
+	} //if
 } //end setArmColors
 
 //Check if the arm is stopped
 //return - whether both joints are stopped
 func (a2 Arm2) isStopped() bool {
-	return a2.arm1.stopped && a2.arm2.stopped
+	return a2.arm1.stopped && a2.arm2.stopped && (a2.arm3 == nil || a2.arm3.stopped)
 } //end isStopped
 
-//InverseKinematics calculates the joint angles given an endpoint
-//Point p - endpoint in Cartesian space
-//float64 ang1 - current angle of first joint
-//float64 ang2 - current angle of second joint
-//float64 a1 - length of first joint
-//float64 a2 - length of second joint
-//return - new first and second joint angles
-func InverseKinematics(p Point, ang1, ang2, a1, a2 float64) (float64, float64) {
-	r := PointDistance(Point{0, 0}, p) //distance from origin to point
-	theta := math.Atan2(p.y, p.x)      //angle counterclockwise from x-axis to point
-
-	q2a := math.Acos((r*r - a1*a1 - a2*a2) / (2 * a1 * a2))                      //second joint angle
-	q1a := theta - math.Abs(math.Atan((a2*math.Sin(q2a))/(a1+a2*math.Cos(q2a)))) //first joint angle
+//Calculate the torque the second joint's weight and the payload put on the first joint
+//float64 q1 - angle of the first joint
+//float64 q2 - angle of the second joint relative to the first
+//return - torque on the first joint from the second joint in Nm
+func (a2 Arm2) calcDistalTorque(q1, q2 float64) float64 {
+	elbowX := a2.arm1.length * math.Cos(q1)             //horizontal distance to the elbow
+	comX := elbowX + a2.arm2.length*0.5*math.Cos(q1+q2) //horizontal distance to the second joint's center of mass
+	tipX := elbowX + a2.arm2.length*math.Cos(q1+q2)     //horizontal distance to the payload
+	return a2.arm2.mass*g*comX + a2.payload*g*tipX      //mgr
+} //end calcDistalTorque
 
-	q2b := -math.Acos((r*r - a1*a1 - a2*a2) / (2 * a1 * a2))                     //second joint angle
-	q1b := theta + math.Abs(math.Atan((a2*math.Sin(q2a))/(a1+a2*math.Cos(q2a)))) //first joint angle
+//Calculate the torque the payload puts on the second joint
+//float64 q1 - angle of the first joint
+//float64 q2 - angle of the second joint relative to the first
+//return - torque on the second joint from the payload in Nm
+func (a2 Arm2) calcPayloadTorque(q1, q2 float64) float64 {
+	return a2.payload * g * a2.arm2.length * math.Cos(q1+q2)
+} //end calcPayloadTorque
 
-	//elbow down in (0,90), elbow up in [90,180)
-	if theta > 0 && theta < math.Pi/2 { //quadrant one
-		return q1b, q2b //elbow down
-	} //if
-	//quadrant two
-	return q1a, q2a //elbow up
-} //end moveToPoint
+//Calculate the static torque needed at each joint to hold up the whole chain
+//float64 q1 - angle of the first joint
+//float64 q2 - angle of the second joint relative to the first
+//return - torque at the first joint and torque at the second joint in Nm
+func (a2 Arm2) calcGravTorques(q1, q2 float64) (float64, float64) {
+	tau1 := a2.arm1.mass*g*a2.arm1.length*0.5*math.Cos(q1) + a2.calcDistalTorque(q1, q2)     //own link plus everything past the elbow
+	tau2 := a2.arm2.mass*g*a2.arm2.length*0.5*math.Cos(q1+q2) + a2.calcPayloadTorque(q1, q2) //own link plus the payload
+	return tau1, tau2
+} //end calcGravTorques
 
 //Manually set the acceleration for both joints (used for testing)
 //float64 acc1 - acceleration for first joint
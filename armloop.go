@@ -5,10 +5,19 @@
 
 package main
 
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
 //Colors
 var yellow [3]int = [3]int{255, 255, 0}  //yellow
 var blue [3]int = [3]int{0, 0, 255}      //blue
 var white [3]int = [3]int{255, 255, 255} //white
+var purple [3]int = [3]int{160, 32, 240} //purple
+var cyan [3]int = [3]int{0, 255, 255}    //cyan
+var red [3]int = [3]int{255, 0, 0}       //red
 
 //Variables
 var calculated bool = false //whether the inverse kinematics has been calculated yet
@@ -18,24 +27,172 @@ var a1, a2 float64          //goal joint angles for the arm to move to
 type State int
 
 const (
-	waiting      State = iota //WAITING state is for a point to move to
-	goalTracking              //GOAL_TRACKING state is for moving towards a point
-	finished                  //arm has successfully moved to a point
-	testing                   //used for testing the physics model
+	waiting        State = iota //WAITING state is for a point to move to
+	goalTracking                //GOAL_TRACKING state is for moving towards a point
+	finished                    //arm has successfully moved to a point
+	physicsTesting              //used for testing the physics model
+	commanding                  //each joint follows its own command in any mode
+	cartesianRate               //the end point follows a commanded velocity
+	unreachable                 //the goal can't be reached, waiting for another
 )
 
 //ArmLoop is the loop that controls the arm
 type ArmLoop struct {
 	arm2  Arm2  //arm to control
-	goal  Point //goal point for arm to move to
+	goal  Goal  //goal for the arm to move to
 	state State //state the arm is in
+
+	controller     Controller     //control law driving the joints
+	controllerName string         //name the controller was registered with
+	goalState      ArmState       //joint angles of the goal at rest
+	wristGoal      float64        //angle of the wrist at the goal, if there is one
+	clawHeading    float64        //heading the claw holds through the waypoints, if there is a wrist
+	ikFallback     bool           //whether to move as close as possible to goals that can't be reached
+	solver         ikSolver       //solver for the goal's joint angles
+	err            error          //why the arm is in the unreachable state
+
+	enc1      encoder       //encoder measuring the first joint
+	enc2      encoder       //encoder measuring the second joint
+	estimator *kalmanfilter //state estimator, nil to use the raw measurements
+	lastMeas  ArmState      //last raw measurement for finite difference velocities
+	stateLog  io.Writer     //where to log the true and estimated state, nil for no log
+	time      float64       //time spent tracking goals in seconds
+
+	criteria arrivalCriteria //rules for arriving at the current goal
+	tracker  arrivalTracker  //rules met so far on the way to the current goal
+
+	move    moveRecord    //recorded motion towards the current goal
+	metrics []moveMetrics //analysis of every finished move, in order
+
+	commands [2]jointCommand //commands for each joint in the commanding state
+
+	planner *planner  //plans paths around obstacles to each goal, nil to go straight
+	path    jointPath //path planned to the current goal
+
+	topp      *toppLimits               //limits to time each move as fast as possible within, nil for the fixed timing
+	moveTimes map[[2]ikSolution]float64 //time-optimal time of each move between two poses, kept for reordering
+
+	waypoints []Goal        //goals passed through without stopping on the way to the goal
+	spline    splineOptions //how to fit the spline through the waypoints
+
+	rates       rateSource //end point velocity in the cartesianRate state
+	rateDamping float64    //damping of the joint rates near singularities
 } //end struct
 
+//Create the state machine for an arm, solving for goals in closed form closest to the current angles and driving it with pid
+//Arm2 a2 - arm to control, with everything IK needs to know about already added
+func newArmLoop(a2 Arm2) ArmLoop {
+	loop := ArmLoop{arm2: a2, state: waiting, solver: analyticSolver{arm2: a2}}
+	loop.setController("pid") //until another is picked
+	return loop
+} //end newArmLoop
+
 //get a string representation of the state
 func (s State) String() string {
-	return [...]string{"waiting", "goalTracking", "finished", "testing"}[s]
+	return [...]string{"waiting", "goalTracking", "finished", "testing", "commanding", "cartesianRate", "unreachable"}[s]
 } //end String
 
+//Solve for the joint angles of the goal, warm started from where the arm is
+//return - the solution, and an error if it doesn't reach the goal
+func (loop *ArmLoop) solveGoal() (ikSolution, error) {
+	s, q3, err := loop.solveGoalFrom(loop.goal, ikSolution{loop.arm2.arm1.angle, loop.arm2.arm2.angle})
+	loop.wristGoal = q3
+	return s, err
+} //end solveGoal
+
+//Solve for the joint angles of any goal the way the goal is solved for, warm started from some angles
+//Goal g - goal to solve for, met by the claw if there is a wrist
+//ikSolution from - angles of the first two joints to start from and stay close to, the wrist starting where it is
+//return - the solution, the wrist angle if there is a wrist, and an error if it doesn't reach the goal
+func (loop *ArmLoop) solveGoalFrom(g Goal, from ikSolution) (ikSolution, float64, error) {
+	start := []float64{from.q1, from.q2}
+	if loop.arm2.arm3 != nil {
+		start = append(start, loop.arm2.arm3.angle)
+	} //if
+	res, err := loop.solver.solve(g, start)
+	q3 := 0.0
+	if loop.arm2.arm3 != nil {
+		q3 = res.angles[2]
+	} //if
+	return ikSolution{res.angles[0], res.angles[1]}, q3, err
+} //end solveGoalFrom
+
+//Plan a path around the obstacles to the goal and have the controller follow it
+//ikSolution goal - joint angles of the goal
+//return - whether a path was found
+func (loop *ArmLoop) followPlan(goal ikSolution) bool {
+	path, err := loop.planner.plan(ikSolution{loop.arm2.arm1.angle, loop.arm2.arm2.angle}, goal)
+	if err != nil {
+		loop.setUnreachable(err)
+		return false
+	} //if
+
+	loop.path = path
+	loop.followTrajectory(loop.timePath(path))
+	return true
+} //end followPlan
+
+//Time a path, as fast as the motors allow if there are limits to time it within
+//jointPath path - joint angles to pass through, ending at the goal
+//return - the trajectory along the path
+func (loop *ArmLoop) timePath(path jointPath) armTrajectory {
+	if loop.topp != nil && len(path) > 1 && jointDistance(path[0], path[len(path)-1]) > 1e-6 {
+		traj, err := loop.arm2.timeOptimal(path, *loop.topp)
+		if err == nil {
+			fmt.Printf("time-optimal move takes %.2fs\n", traj.duration())
+			return traj
+		} //if
+		fmt.Println(err, "- using the fixed timing")
+	} //if
+	return loop.arm2.newPathTrajectory(path)
+} //end timePath
+
+//Check if the controller follows trajectories, and so can pass through waypoints without stopping
+func (loop *ArmLoop) followsTrajectories() bool {
+	c, ok := loop.controller.(*pidFFController)
+	return ok && c.trajectory
+} //end followsTrajectories
+
+//Have the controller follow a trajectory to the goal
+//armTrajectory traj - trajectory for both joints, ending at the goal
+func (loop *ArmLoop) followTrajectory(traj armTrajectory) {
+	if f, ok := loop.controller.(trajectoryFollower); !ok || !f.followTrajectory(traj, loop.time) {
+		fmt.Println(loop.controllerName, "can't follow a trajectory, going straight to the goal")
+	} //if
+} //end followTrajectory
+
+//Fit a spline from where the arm is through the waypoints to the goal and have the controller follow it
+//ikSolution goal - joint angles of the goal
+func (loop *ArmLoop) followSpline(goal ikSolution) {
+	//with a wrist the claw holds the goal's heading the whole way, so it meets each waypoint as the wrist passes it
+	loop.clawHeading = goal.q1 + goal.q2 + loop.wristGoal
+
+	//each waypoint's joint angles closest to the last, skipping those that can't be reached
+	path := jointPath{{loop.arm2.arm1.angle, loop.arm2.arm2.angle}}
+	for _, wp := range loop.waypoints {
+		if loop.arm2.arm3 != nil {
+			wp = orientedGoal(wp.point, loop.clawHeading)
+		} //if
+		s, _, err := loop.solveGoalFrom(wp, path[len(path)-1])
+		var oErr *orientationError
+		if isUnreachable(err) || errors.As(err, &oErr) {
+			fmt.Println(err, "- skipping it")
+			continue
+		} //if
+		path = append(path, s)
+	} //loop
+	path = append(path, goal)
+	loop.path = path
+
+	if loop.topp != nil { //the fastest timing along a smooth path through the waypoints
+		loop.followTrajectory(loop.timePath(path))
+	} else if loop.spline.space == cartesianSpace {
+		loop.followTrajectory(loop.arm2.newCartesianTrajectory(path, loop.spline))
+	} else {
+		loop.followTrajectory(fitSpline(path, loop.arm2.calcSegmentTimes(path), loop.spline))
+	} //if
+} //end followSpline
+
 //Set the state
 //State s - new state for the arm to be in
 func (loop *ArmLoop) setState(s State) {
@@ -55,18 +212,85 @@ func (loop *ArmLoop) onLoop() {
 		loop.arm2.arm1.color = color1
 		color2 := loop.arm2.arm2.calcColor(1)
 		loop.arm2.arm2.color = color2
+		if loop.arm2.arm3 != nil {
+			loop.arm2.arm3.color = loop.arm2.arm3.calcColor(1)
+		} //if
 
 		//calculate joint angles only on first loop in this state
 		if !calculated { //if the angle hasn't been calculated already
 			//calculate it
-			a1, a2 = InverseKinematics(loop.goal, loop.arm2.arm1.angle, loop.arm2.arm2.angle,
-				loop.arm2.arm1.length, loop.arm2.arm2.length)
+			solution, err := loop.solveGoal()
+			if isUnreachable(err) {
+				if !loop.ikFallback { //stop rather than chase a goal that can't be reached
+					loop.setUnreachable(err)
+					break
+				} //if
+				fmt.Println(err, "- moving there instead")
+			} else if err != nil {
+				fmt.Println(err)
+			} //if
+			a1, a2 = solution.q1, solution.q2
 			calculated = true //set to true so it doesn't ccalculate again
+
+			loop.goalState = ArmState{a1, 0, a2, 0}
+
+			//start the finite differences from where the arm is and move the linearization to the goal
+			loop.lastMeas = loop.arm2.getState()
+			if loop.estimator != nil && !loop.estimator.extended {
+				loop.estimator.relinearize(loop.arm2, loop.goalState)
+			} //if
+
+			loop.controller.reset(loop.arm2.getState(), loop.goalState, loop.time)
+			if len(loop.waypoints) > 0 {
+				loop.followSpline(ikSolution{a1, a2})
+			} else if loop.planner != nil && !loop.followPlan(ikSolution{a1, a2}) {
+				break
+			} else if loop.planner == nil && loop.topp != nil {
+				loop.path = jointPath{{loop.arm2.arm1.angle, loop.arm2.arm2.angle}, {a1, a2}}
+				loop.followTrajectory(loop.timePath(loop.path))
+			} //if
+			loop.tracker = newArrivalTracker(loop.criteria)
+			loop.move = newMoveRecord(loop.arm2, loop.goalState)
 		} //if
 
+		//control from the measured state rather than the true state
+		truth := loop.arm2.getState()
+		meas := loop.measureState()
+
 		//move to joint angles
-		loop.arm2.arm1.movePIDFF(a1, loop.arm2.arm1.angle, ToRadians(1))
-		loop.arm2.arm2.movePIDFF(a2, loop.arm2.arm2.angle, ToRadians(1))
+		v1, v2 := loop.controller.calculate(meas, loop.goalState, loop.time)
+		loop.arm2.arm1.moveVoltage(v1)
+		loop.arm2.arm2.moveVoltage(v2)
+		if loop.arm2.arm3 != nil { //the wrist holds its own angle, or the claw's heading through waypoints
+			wristGoal := loop.wristGoal
+			if len(loop.waypoints) > 0 {
+				wristGoal = nearestTurn(loop.clawHeading-loop.arm2.arm1.angle-loop.arm2.arm2.angle, loop.arm2.arm3.angle)
+				wristGoal = loop.arm2.arm3.clampToLimits(wristGoal)
+			} //if
+			loop.arm2.arm3.movePIDFF(wristGoal, loop.arm2.arm3.angle, ToRadians(1))
+		} //if
+		loop.move.record(loop.arm2, loop.time+dt)
+
+		//the arm is stopped once it meets the goal's rules
+		arrived, met := loop.tracker.update(loop.arm2, loop.goalState, loop.time)
+		for _, r := range met { //each rule once, when it is first met
+			fmt.Printf("%s met at %.2fs\n", r, loop.time)
+		} //loop
+		loop.arm2.arm1.stopped = arrived
+		loop.arm2.arm2.stopped = arrived
+
+		//predict the next state from the voltages that were applied
+		if loop.estimator != nil {
+			loop.estimator.predict(loop.arm2, loop.arm2.arm1.voltage, loop.arm2.arm2.voltage)
+		} //if
+		if loop.stateLog != nil {
+			var est *ArmState
+			if loop.estimator != nil {
+				est = &meas
+			} //if
+			logEstimate(loop.stateLog, loop.time, truth, est)
+		} //if
+		loop.time += dt
 
 		//graphically update the arm
 		loop.arm2.update()
@@ -74,23 +298,181 @@ func (loop *ArmLoop) onLoop() {
 
 	case finished:
 		loop.arm2.setArmColors(blue) //blue for finished
-		calculated = false           //reset the calculated state so the arm calculates the new goal angle next time
+
+		//analyze the move on the first loop after arriving
+		if calculated {
+			loop.metrics = append(loop.metrics, calcMoveMetrics(loop.move))
+			fmt.Println(loop.metrics[len(loop.metrics)-1])
+		} //if
+		calculated = false //reset the calculated state so the arm calculates the new goal angle next time
 		// loop.arm2.rest()
 		//delay
 		break
 
-	case testing:
+	case unreachable:
+		loop.arm2.setArmColors(red) //red for a goal that can't be reached
+		break
+
+	case physicsTesting:
 		loop.arm2.setArmColors(white)
 		loop.arm2.rest()
 		break
+
+	case commanding:
+		loop.arm2.setArmColors(purple) //purple for commanding
+		loop.arm2.scheduleGains()      //position mode uses the joint PIDs
+
+		//every mode holds up the whole chain
+		tau1, tau2 := loop.arm2.calcGravTorques(loop.arm2.arm1.angle, loop.arm2.arm2.angle)
+		loop.arm2.arm1.command(loop.commands[0], tau1)
+		loop.arm2.arm2.command(loop.commands[1], tau2)
+		loop.arm2.arm1.stopped = false
+		loop.arm2.arm2.stopped = false
+
+		//graphically update the arm
+		loop.arm2.update()
+		break
+
+	case cartesianRate:
+		loop.arm2.setArmColors(cyan) //cyan for cartesian rate control
+
+		//resolve the end point velocity into joint velocities
+		q1, q2 := loop.arm2.arm1.angle, loop.arm2.arm2.angle
+		v := loop.rates(loop.arm2.calcEndPoint(q1, q2), loop.time)
+		qd1, qd2 := loop.arm2.calcJointRates(q1, q2, v, loop.rateDamping)
+
+		tau1, tau2 := loop.arm2.calcGravTorques(q1, q2)
+		loop.arm2.arm1.command(jointCommand{velocityMode, qd1}, tau1)
+		loop.arm2.arm2.command(jointCommand{velocityMode, qd2}, tau2)
+		loop.arm2.arm1.stopped = false
+		loop.arm2.arm2.stopped = false
+		loop.time += dt
+
+		//graphically update the arm
+		loop.arm2.update()
+		break
 	} //switch
 } //end onLoop
 
+//Switch the controller driving the arm
+//string name - name of a registered controller
+//return - an error if there is no controller with the name
+func (loop *ArmLoop) setController(name string) error {
+	c, err := NewController(name, loop.arm2)
+	if err != nil {
+		return err
+	} //if
+
+	//pick up the current goal where the last controller left off
+	if loop.state == goalTracking && calculated {
+		c.reset(loop.arm2.getState(), loop.goalState, loop.time)
+	} //if
+	loop.controller = c
+	loop.controllerName = name
+
+	return nil
+} //end setController
+
+//Command a joint in any mode, switching the state machine to commanding
+//int joint - joint to command, 1 or 2
+//JointMode mode - how to command the joint
+//float64 value - setpoint in the units of the mode
+func (loop *ArmLoop) setJointCommand(joint int, mode JointMode, value float64) {
+	if loop.state != commanding { //hold the other joint where it is
+		loop.commands[0] = jointCommand{positionMode, loop.arm2.arm1.angle}
+		loop.commands[1] = jointCommand{positionMode, loop.arm2.arm2.angle}
+	} //if
+
+	loop.commands[joint-1] = jointCommand{mode, value}
+	arm := loop.arm2.arm1
+	if joint == 2 {
+		arm = loop.arm2.arm2
+	} //if
+	arm.pid.reset() //nothing carried over from the last goal or command
+	arm.velPID.reset()
+	arm.curPID.reset()
+	loop.state = commanding
+	loop.arm2.arm1.stopped = false //moving again, not finished
+	loop.arm2.arm2.stopped = false
+	calculated = false //work out the goal again when tracking resumes
+} //end setJointCommand
+
+//Drive the end point with a velocity, switching the state machine to cartesianRate
+//rateSource rates - gives the desired end point velocity
+//float64 damping - damping of the joint rates near singularities
+func (loop *ArmLoop) setRateSource(rates rateSource, damping float64) {
+	loop.rates = rates
+	loop.rateDamping = damping
+	loop.state = cartesianRate
+	loop.arm2.arm1.stopped = false //moving again, not finished
+	loop.arm2.arm2.stopped = false
+	calculated = false //work out the goal again when tracking resumes
+} //end setRateSource
+
+//Measure the state of the arm through its encoders
+//return - the estimated state with an estimator, otherwise the raw angles with finite difference velocities
+func (loop *ArmLoop) measureState() ArmState {
+	z1 := loop.enc1.measure(loop.arm2.arm1.angle)
+	z2 := loop.enc2.measure(loop.arm2.arm2.angle)
+
+	if loop.estimator != nil {
+		loop.estimator.correct(z1, z2)
+		return loop.estimator.x
+	} //if
+
+	meas := ArmState{z1, (z1 - loop.lastMeas[0]) / dt, z2, (z2 - loop.lastMeas[2]) / dt}
+	loop.lastMeas = meas
+	return meas
+} //end measureState
+
 //Set the goal point for the state machine
 //Point p - new point to be the goal for the state machine
 func (loop *ArmLoop) setGoal(p Point) {
-	loop.goal = p
+	loop.setGoalWithArrival(pointGoal(p), defaultArrival)
+} //end setGoal
+
+//Set goals to pass through without stopping, arriving at the last one
+//[]Goal goals - goals in order, at least one
+//arrivalCriteria criteria - rules the arm must meet to arrive at the last goal
+func (loop *ArmLoop) setWaypoints(goals []Goal, criteria arrivalCriteria) {
+	loop.setGoalWithArrival(goals[len(goals)-1], criteria)
+	loop.waypoints = append([]Goal{}, goals[:len(goals)-1]...)
+} //end setWaypoints
+
+//Stop tracking a goal that can't be reached
+//error err - why the goal can't be reached
+func (loop *ArmLoop) setUnreachable(err error) {
+	fmt.Println(err)
+	loop.err = err
+	loop.state = unreachable
+	calculated = false //work out the next goal from scratch
+} //end setUnreachable
+
+//Check if solving for a goal failed to reach it, rather than only just reaching it
+//error err - error from solving for the goal
+//return - whether the point was out of reach, past the limits or not converged on
+func isUnreachable(err error) bool {
+	switch e := err.(type) {
+	case *ikError:
+		return e.reach != singular
+	case *convergenceError:
+		return true
+	} //switch
+	return false
+} //end isUnreachable
+
+//Set the goal with its own rules for arriving
+//Goal g - goal point and approach angle
+//arrivalCriteria criteria - rules the arm must meet to arrive at the goal
+func (loop *ArmLoop) setGoalWithArrival(g Goal, criteria arrivalCriteria) {
+	loop.goal = g
+	loop.waypoints = nil
+	loop.criteria = criteria
+	calculated = false //work out the angles for the new goal
 	loop.state = goalTracking
 	loop.arm2.arm1.stopped = false
 	loop.arm2.arm2.stopped = false
-} //end setGoal
+	if loop.arm2.arm3 != nil {
+		loop.arm2.arm3.stopped = false
+	} //if
+} //end setGoalWithArrival
@@ -0,0 +1,101 @@
+//arrival
+//Created on: 10/19/2026
+//Rules for deciding when the arm has arrived at its goal
+
+package main
+
+import (
+	"math"
+)
+
+//arrivalRule is one of the rules a goal can require before the arm has arrived
+type arrivalRule int
+
+const (
+	jointRule    arrivalRule = iota //both joints within a tolerance of their goal angles
+	radiusRule                      //end point within a radius of the goal point
+	velocityRule                    //both joints moving slowly enough
+	dwellRule                       //all the other rules held for long enough
+	numArrivalRules
+)
+
+//get a string representation of the rule
+func (r arrivalRule) String() string {
+	return [...]string{"joint tolerance", "end point radius", "velocity", "dwell"}[r]
+} //end String
+
+//arrivalCriteria are the rules for arriving at a goal, with zero turning a rule off
+type arrivalCriteria struct {
+	jointTolerance float64 //largest error either joint can have in radians
+	radius         float64 //largest distance the end point can be from the goal point in meters
+	velocity       float64 //fastest either joint can move as a fraction of its max velocity
+	dwell          float64 //time the other rules must hold for in seconds
+} //end struct
+
+//criteria used when a goal does not have its own, the same as a joint stopping within a degree
+var defaultArrival = arrivalCriteria{jointTolerance: ToRadians(1), velocity: 0.1}
+
+//Check if a rule is used by the criteria
+//arrivalRule r - rule to check
+//return - whether the rule has to be met to arrive
+func (c arrivalCriteria) uses(r arrivalRule) bool {
+	return [...]float64{c.jointTolerance, c.radius, c.velocity, c.dwell}[r] > 0
+} //end uses
+
+//arrivalTracker follows which rules have been met on the way to a goal
+type arrivalTracker struct {
+	criteria arrivalCriteria          //rules for the goal
+	metAt    [numArrivalRules]float64 //time each rule was first met in seconds, -1 if not yet
+	holding  [numArrivalRules]bool    //whether each rule holds right now
+	since    float64                  //time all the rules other than dwell started holding, -1 if they do not
+} //end struct
+
+//Create a tracker for a new goal
+//arrivalCriteria criteria - rules for the goal
+func newArrivalTracker(criteria arrivalCriteria) arrivalTracker {
+	tracker := arrivalTracker{criteria: criteria, since: -1}
+	for i := range tracker.metAt {
+		tracker.metAt[i] = -1
+	} //loop
+	return tracker
+} //end newArrivalTracker
+
+//Check the rules against the arm
+//Arm2 a2 - arm moving to the goal
+//ArmState goal - goal state of the joints
+//float64 t - simulation time in seconds
+//return - whether the arm has arrived, and the rules met for the first time
+func (tr *arrivalTracker) update(a2 Arm2, goal ArmState, t float64) (bool, []arrivalRule) {
+	c := tr.criteria
+	x := a2.getState()
+	tip, target := a2.calcEndPoint(x[0], x[2]), a2.calcEndPoint(goal[0], goal[2])
+
+	tr.holding[jointRule] = math.Abs(goal[0]-x[0]) <= c.jointTolerance && math.Abs(goal[2]-x[2]) <= c.jointTolerance
+	tr.holding[radiusRule] = WithinBounds(target, tip, c.radius)
+	tr.holding[velocityRule] = math.Abs(x[1]) < c.velocity*a2.arm1.maxVel && math.Abs(x[3]) < c.velocity*a2.arm2.maxVel
+
+	//the dwell timer runs while everything else holds
+	all := true
+	for r := jointRule; r < dwellRule; r++ {
+		if c.uses(r) && !tr.holding[r] {
+			all = false
+		} //if
+	} //loop
+	if !all {
+		tr.since = -1
+	} else if tr.since < 0 {
+		tr.since = t
+	} //if
+	tr.holding[dwellRule] = all && t-tr.since >= c.dwell
+
+	//report each rule the first time it holds
+	var met []arrivalRule
+	for r := jointRule; r < numArrivalRules; r++ {
+		if c.uses(r) && tr.holding[r] && tr.metAt[r] < 0 {
+			tr.metAt[r] = t
+			met = append(met, r)
+		} //if
+	} //loop
+
+	return tr.holding[dwellRule], met
+} //end update
@@ -0,0 +1,142 @@
+//chain
+//Created on: 10/19/2026
+//Serial chain of revolute joints in a plane, with its Jacobian and how well conditioned it is
+
+package main
+
+import (
+	"math"
+)
+
+//planarChain is a serial chain of revolute joints moving in a plane, with the base at the origin
+type planarChain struct {
+	lengths []float64 //length of each link in meters
+	angles  []float64 //angle of each joint relative to the link before it in radians
+} //end struct
+
+//singularity is how a chain is close to losing a direction it can move in
+type singularity int
+
+const (
+	notSingular singularity = iota //can move the end point in every direction
+	extended                       //stretched straight out, can't reach further
+	folded                         //folded back on itself, can't pull in closer
+)
+
+//get a string representation of the singularity
+func (s singularity) String() string {
+	return [...]string{"not singular", "extended", "folded"}[s]
+} //end String
+
+//conditioning is how well a chain can move its end point at a pose
+type conditioning struct {
+	manipulability float64     //area of the velocity ellipse per unit joint speeds, zero at a singularity
+	condition      float64     //ratio of the largest to the smallest singular value of the Jacobian, Inf at a singularity
+	axes           [2]Point    //principal axes of the velocity ellipse, longest first, in meters/radian
+	near           singularity //singularity the chain is close to, if any
+} //end struct
+
+//Get the two-jointed arm as a chain at some joint angles
+//float64 q1 - angle of the first joint
+//float64 q2 - angle of the second joint relative to the first
+func (a2 Arm2) chainAt(q1, q2 float64) planarChain {
+	return planarChain{[]float64{a2.arm1.length, a2.arm2.length}, []float64{q1, q2}}
+} //end chainAt
+
+//Calculate the position of the base and the end of every link
+//return - the base then the end of each link in meters
+func (c planarChain) jointPoints() []Point {
+	points := []Point{{0, 0}}
+	p, heading := Point{0, 0}, 0.0
+	for i, l := range c.lengths {
+		heading += c.angles[i]
+		p = Point{p.x + l*math.Cos(heading), p.y + l*math.Sin(heading)}
+		points = append(points, p)
+	} //loop
+	return points
+} //end jointPoints
+
+//Calculate the end point of the chain
+//return - end of the last link in meters
+func (c planarChain) endPoint() Point {
+	points := c.jointPoints()
+	return points[len(points)-1]
+} //end endPoint
+
+//Calculate the geometric Jacobian of the chain
+//return - 3xn matrix mapping joint velocities to end point x, y and heading velocities
+func (c planarChain) jacobian() matrix {
+	n := len(c.lengths)
+	points := c.jointPoints()
+	tip := points[n]
+
+	//each joint swings the tip about itself, perpendicular to the line between them
+	J := newMatrix(3, n)
+	for i := 0; i < n; i++ {
+		J.set(0, i, -(tip.y - points[i].y))
+		J.set(1, i, tip.x-points[i].x)
+		J.set(2, i, 1)
+	} //loop
+	return J
+} //end jacobian
+
+//Calculate the rows of the Jacobian for the end point's position
+//return - 2xn matrix mapping joint velocities to end point x and y velocities
+func (c planarChain) positionJacobian() matrix {
+	J := c.jacobian()
+	Jp := newMatrix(2, J.cols)
+	for i := 0; i < J.cols; i++ {
+		Jp.set(0, i, J.at(0, i))
+		Jp.set(1, i, J.at(1, i))
+	} //loop
+	return Jp
+} //end positionJacobian
+
+//Calculate how well the chain can move its end point
+//float64 tolerance - smallest singular value, as a fraction of the chain's reach, before it counts as near a singularity
+//return - the manipulability, condition number, velocity ellipse and any nearby singularity
+func (c planarChain) calcConditioning(tolerance float64) conditioning {
+	J := c.positionJacobian()
+	JJt := J.mul(J.transpose())
+
+	//eigenvalues and eigenvectors of the symmetric 2x2 JJ'
+	a, b, d := JJt.at(0, 0), JJt.at(0, 1), JJt.at(1, 1)
+	mean, diff := (a+d)/2, math.Hypot((a-d)/2, b)
+	big, small := mean+diff, math.Max(0, mean-diff)
+	dir := 0.5 * math.Atan2(2*b, a-d) //direction of the largest eigenvalue
+
+	sMax, sMin := math.Sqrt(big), math.Sqrt(small)
+	cond := conditioning{manipulability: sMax * sMin, condition: math.Inf(1)}
+	if sMin > 0 {
+		cond.condition = sMax / sMin
+	} //if
+	cond.axes[0] = scalePoint(Point{math.Cos(dir), math.Sin(dir)}, sMax)
+	cond.axes[1] = scalePoint(Point{-math.Sin(dir), math.Cos(dir)}, sMin)
+
+	//close to losing a direction, extended if every joint past the first is nearly straight
+	reach := 0.0
+	for _, l := range c.lengths {
+		reach += l
+	} //loop
+	if sMin < tolerance*reach {
+		cond.near = extended
+		for _, q := range c.angles[1:] {
+			if math.Cos(q) < 0 {
+				cond.near = folded
+			} //if
+		} //loop
+	} //if
+
+	return cond
+} //end calcConditioning
+
+//Calculate how well the arm can move its end point at some joint angles
+//float64 q1 - angle of the first joint
+//float64 q2 - angle of the second joint relative to the first
+//return - the manipulability, condition number, velocity ellipse and any nearby singularity
+func (a2 Arm2) calcConditioning(q1, q2 float64) conditioning {
+	return a2.chainAt(q1, q2).calcConditioning(singularTolerance)
+} //end calcConditioning
+
+//smallest singular value of the Jacobian, as a fraction of the reach, before a pose counts as near a singularity
+const singularTolerance = 0.05
@@ -0,0 +1,101 @@
+//console
+//Created on: 10/19/2026
+//Commands typed into the terminal while the simulator runs
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//lines typed into the console, waiting to be run on the next frame
+var consoleLines = make(chan string, 16)
+
+//Read commands from the console a line at a time until it closes
+//io.Reader r - where the commands are typed, such as stdin
+func readConsole(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		consoleLines <- scanner.Text()
+	} //loop
+} //end readConsole
+
+//Run every command waiting from the console, printing any that fail
+//*ArmLoop loop - state machine the commands act on
+func runConsole(loop *ArmLoop) {
+	for {
+		select {
+		case line := <-consoleLines:
+			if err := loop.runCommand(line); err != nil {
+				fmt.Println(err)
+			} //if
+		default: //nothing else typed
+			return
+		} //select
+	} //loop
+} //end runConsole
+
+//Run a command typed into the console
+//string line - the command and its arguments separated by spaces
+//return - an error if the command is unknown or its arguments are invalid
+func (loop *ArmLoop) runCommand(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	} //if
+
+	switch fields[0] {
+	case "ctrl": //switch the controller
+		if len(fields) != 2 {
+			return fmt.Errorf("usage: ctrl <name>, one of %v", ControllerNames())
+		} //if
+		if err := loop.setController(fields[1]); err != nil {
+			return err
+		} //if
+		fmt.Println("switched to", fields[1])
+	case "joint": //command a joint in any mode
+		if len(fields) != 4 || (fields[1] != "1" && fields[1] != "2") {
+			return fmt.Errorf("usage: joint <1|2> <percentOutput|position|velocity|current> <value>")
+		} //if
+		mode, err := parseJointMode(fields[2])
+		if err != nil {
+			return err
+		} //if
+		value, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil {
+			return fmt.Errorf("bad value %q for joint %s", fields[3], fields[1])
+		} //if
+		if mode == positionMode || mode == velocityMode { //typed in degrees
+			value = ToRadians(value)
+		} //if
+		loop.setJointCommand(int(fields[1][0]-'0'), mode, value)
+	case "jog": //move the end point at a velocity
+		v, err := ParseFloats(strings.Join(fields[1:], ","))
+		if err != nil || len(v) != 2 {
+			return fmt.Errorf("usage: jog <x velocity> <y velocity>")
+		} //if
+		loop.setRateSource(constantRateSource(Point{v[0], v[1]}), 0.1)
+	case "line": //move the end point along a straight line to a point
+		v, err := ParseFloats(strings.Join(fields[1:], ","))
+		if err != nil || len(v) != 3 || v[2] <= 0 {
+			return fmt.Errorf("usage: line <x> <y> <seconds>, with a time above 0")
+		} //if
+		tip := loop.arm2.calcEndPoint(loop.arm2.arm1.angle, loop.arm2.arm2.angle)
+		loop.setRateSource(pathRateSource(linePath(tip, Point{v[0], v[1]}, loop.time, v[2]), 4), 0.1)
+	case "go": //run the reordered points
+		if reorder == nil {
+			return fmt.Errorf("points only wait to be run with -reorder")
+		} //if
+		if err := releasePoints(); err != nil {
+			return err
+		} //if
+		fmt.Println("running", len(pts)-pointIndex, "points")
+	default:
+		return fmt.Errorf("unknown command %q, use ctrl, joint, jog, line or go", fields[0])
+	} //switch
+	return nil
+} //end runCommand
@@ -0,0 +1,143 @@
+//controller
+//Created on: 10/19/2026
+//Interface for the control laws that drive the arm and a registry to pick them by name
+
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+//Controller is a control law that drives both joints of the arm
+type Controller interface {
+	//calculate the voltages for both joints
+	//ArmState state - measured state of the arm
+	//ArmState goal - state to drive the arm to
+	//float64 t - simulation time in seconds
+	//return - voltages for the first and second joints
+	calculate(state, goal ArmState, t float64) (float64, float64)
+
+	//prepare to move to a new goal
+	//ArmState state - measured state of the arm
+	//ArmState goal - state to drive the arm to
+	//float64 t - simulation time in seconds
+	reset(state, goal ArmState, t float64)
+} //end interface
+
+//trajectoryFollower is a controller that can follow a trajectory to the goal instead of going straight
+type trajectoryFollower interface {
+	//follow a trajectory to the goal, after reset
+	//armTrajectory traj - trajectory for both joints, ending at the goal
+	//float64 t - simulation time in seconds
+	//return - whether the controller follows the trajectory
+	followTrajectory(traj armTrajectory, t float64) bool
+} //end interface
+
+//ControllerFactory creates a controller for an arm
+type ControllerFactory func(a2 Arm2) Controller
+
+//registered controllers by name
+var controllers = map[string]ControllerFactory{}
+
+//controllerSettings are the settings the registered controllers are created with
+type controllerSettings struct {
+	lqrQ       []float64 //LQR state weights for [angle1, vel1, angle2, vel2], also used by MPC
+	lqrR       []float64 //LQR input weights for [voltage1, voltage2], also used by MPC
+	mpcHorizon int       //MPC horizon in time steps
+	mpcCurrent float64   //MPC current limit per motor in Amps, 0 for none
+	mpcLimits  bool      //whether MPC keeps the joints within their limits
+	impK       []float64 //impedance stiffness along x and y in N/m
+	impD       []float64 //impedance damping along x and y in Ns/m
+} //end struct
+
+//settings for every controller created from the registry, changed before creating one to configure it
+var ctrlSettings = controllerSettings{lqrQ: lqrDefaultQ, lqrR: lqrDefaultR, mpcHorizon: 25,
+	impK: impedanceDefaultK, impD: impedanceDefaultD}
+
+//built-in controllers
+func init() {
+	RegisterController("pid", func(a2 Arm2) Controller { return &pidFFController{arm2: a2} })
+	RegisterController("pid-id", func(a2 Arm2) Controller { return &pidFFController{arm2: a2, trajectory: true} })
+} //end init
+
+//RegisterController adds a controller that can be picked by name, replacing any with the same name
+//string name - name of the controller
+//ControllerFactory factory - creates the controller
+func RegisterController(name string, factory ControllerFactory) {
+	controllers[name] = factory
+} //end RegisterController
+
+//NewController creates a registered controller
+//string name - name of the controller
+//Arm2 a2 - arm to control
+//return - the controller, or an error if there is none with the name
+func NewController(name string, a2 Arm2) (Controller, error) {
+	factory, ok := controllers[name]
+	if !ok {
+		return nil, fmt.Errorf("no controller named %q, have %v", name, ControllerNames())
+	} //if
+	return factory(a2), nil
+} //end NewController
+
+//ControllerNames gets the names of all registered controllers in order
+func ControllerNames() []string {
+	names := make([]string, 0, len(controllers))
+	for name := range controllers {
+		names = append(names, name)
+	} //loop
+	sort.Strings(names)
+	return names
+} //end ControllerNames
+
+//pidFFController is PID on each joint plus a feedforward
+type pidFFController struct {
+	arm2       Arm2          //arm to control
+	trajectory bool          //whether to follow a trajectory with inverse dynamics or hold with gravity
+	traj       armTrajectory //trajectories for both joints to the goal
+	start      float64       //time the trajectories started
+} //end struct
+
+//Calculate the voltages for both joints
+//ArmState state - measured state of the arm
+//ArmState goal - state to drive the arm to
+//float64 t - simulation time in seconds
+//return - voltages for the first and second joints
+func (c *pidFFController) calculate(state, goal ArmState, t float64) (float64, float64) {
+	c.arm2.scheduleGains()
+	setpoint1, setpoint2 := goal[0], goal[2]
+	var ff1, ff2 float64
+
+	if c.trajectory { //follow the trajectories, PID only corrects the error from them
+		sp1, sp2 := c.traj.sample(t - c.start)
+		setpoint1, setpoint2 = sp1.pos, sp2.pos
+		ff1, ff2 = calcIDFFArm2(c.arm2, sp1, sp2)
+	} else { //hold up the whole chain
+		ff1, ff2 = calcFFArm2(c.arm2, 0, 0, 0, 0)
+	} //if
+
+	v1 := MaxVoltage*OutputClamp(c.arm2.arm1.pid.calcPID(setpoint1, state[0], ToRadians(1)), -1, 1) + ff1
+	v2 := MaxVoltage*OutputClamp(c.arm2.arm2.pid.calcPID(setpoint2, state[2], ToRadians(1)), -1, 1) + ff2
+	return v1, v2
+} //end calculate
+
+//Plan the trajectories to a new goal
+//ArmState state - measured state of the arm
+//ArmState goal - state to drive the arm to
+//float64 t - simulation time in seconds
+func (c *pidFFController) reset(state, goal ArmState, t float64) {
+	c.arm2.arm1.pid.reset() //no sum or derivative carried over from the last goal
+	c.arm2.arm2.pid.reset()
+	c.traj = c.arm2.newPathTrajectory(jointPath{{state[0], state[2]}, {goal[0], goal[2]}})
+	c.start = t
+} //end reset
+
+//Follow another trajectory to the goal
+//armTrajectory traj - trajectory for both joints, ending at the goal
+//float64 t - simulation time in seconds
+//return - whether the controller follows trajectories, holding with gravity otherwise
+func (c *pidFFController) followTrajectory(traj armTrajectory, t float64) bool {
+	c.traj = traj
+	c.start = t
+	return c.trajectory
+} //end followTrajectory
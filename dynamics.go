@@ -0,0 +1,77 @@
+//dynamics
+//Created on: 10/19/2026
+//Model of the two-jointed arm's dynamics used for feedforward and prediction
+
+package main
+
+//ArmState is the state of a two-jointed arm as [angle1, vel1, angle2, vel2]
+//with the second joint's angle and velocity relative to the first joint
+type ArmState [4]float64
+
+//jointSetpoint is the desired position, velocity and acceleration of a joint
+type jointSetpoint struct {
+	pos float64 //angle in radians
+	vel float64 //angular velocity in radians/second
+	acc float64 //angular acceleration in radians/second^2
+} //end struct
+
+//Get the current state of the arm
+//return - angles and velocities of both joints
+func (a2 Arm2) getState() ArmState {
+	return ArmState{a2.arm1.angle, a2.arm1.vel, a2.arm2.angle, a2.arm2.vel}
+} //end getState
+
+//Calculate the rate of change of the arm's state from the model
+//ArmState x - state of the arm
+//float64 v1 - voltage applied to the first joint
+//float64 v2 - voltage applied to the second joint
+//return - the derivative of the state
+func (a2 Arm2) calcStateDerivative(x ArmState, v1, v2 float64) ArmState {
+	acc1 := a2.arm1.calcAccelAt(x[0], x[1], v1, a2.calcDistalTorque(x[0], x[2]))
+	acc2 := a2.arm2.calcAccelAt(x[0]+x[2], x[3], v2, a2.calcPayloadTorque(x[0], x[2]))
+
+	return ArmState{x[1], acc1, x[3], acc2}
+} //end calcStateDerivative
+
+//Predict the state of the arm one time step later the same way the simulation steps it
+//ArmState x - state of the arm
+//float64 v1 - voltage applied to the first joint
+//float64 v2 - voltage applied to the second joint
+//return - the state after one time step
+func (a2 Arm2) predict(x ArmState, v1, v2 float64) ArmState {
+	v1 = OutputClamp(v1, -MaxVoltage, MaxVoltage)
+	v2 = OutputClamp(v2, -MaxVoltage, MaxVoltage)
+
+	dx := a2.calcStateDerivative(x, v1, v2)
+	vel1 := x[1] + dx[1]*dt
+	vel2 := x[3] + dx[3]*dt
+
+	return ArmState{x[0] + vel1*dt, vel1, x[2] + vel2*dt, vel2}
+} //end predict
+
+//Calculate the joint torques required to follow a setpoint from the arm's dynamics
+//jointSetpoint sp1 - desired motion of the first joint
+//jointSetpoint sp2 - desired motion of the second joint
+//return - torques at the first and second joints in Nm
+func (a2 Arm2) calcInverseDynamics(sp1, sp2 jointSetpoint) (float64, float64) {
+	grav1, grav2 := a2.calcGravTorques(sp1.pos, sp2.pos)
+	return a2.arm1.moi*sp1.acc + grav1, a2.arm2.moi*sp2.acc + grav2
+} //end calcInverseDynamics
+
+//Convert a torque at the joint to the voltage that produces it while the joint is moving
+//float64 torque - torque at the joint in Nm
+//float64 vel - angular velocity of the joint in radians/second
+//return - voltage to apply to the motors
+func (a Arm) torqueToVoltageAt(torque, vel float64) float64 {
+	return a.torqueToVoltage(torque) + a.calcBackEMF(vel)
+} //end torqueToVoltageAt
+
+//calculate the voltages required to follow a trajectory from the inverse dynamics of the arm
+//Arm2 a2 - arm to drive
+//jointSetpoint sp1 - desired motion of the first joint
+//jointSetpoint sp2 - desired motion of the second joint
+//return - feedforward voltages for the first and second joints
+func calcIDFFArm2(a2 Arm2, sp1, sp2 jointSetpoint) (float64, float64) {
+	tau1, tau2 := a2.calcInverseDynamics(sp1, sp2)
+	return a2.arm1.torqueToVoltageAt(tau1, sp1.vel), a2.arm2.torqueToVoltageAt(tau2, sp2.vel)
+} //end calcIDFFArm2
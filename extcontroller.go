@@ -0,0 +1,177 @@
+//extcontroller
+//Created on: 10/19/2026
+//Controller that runs in another process and talks to the simulator with line-delimited JSON
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+//extMessage is sent to the outside controller, a "reset" for each new goal and a "step" each control tick
+type extMessage struct {
+	Type  string     `json:"type"`  //"reset" or "step"
+	Time  float64    `json:"time"`  //simulation time in seconds
+	State [4]float64 `json:"state"` //measured [angle1, vel1, angle2, vel2]
+	Goal  [4]float64 `json:"goal"`  //goal [angle1, vel1, angle2, vel2]
+
+	//how well the arm can move its end point at the measured angles
+	Manipulability float64 `json:"manipulability"` //zero at a singularity
+	InvCondition   float64 `json:"invCondition"`   //smallest over largest singular value of the Jacobian, zero at a singularity
+	Near           string  `json:"near"`           //singularity the arm is close to, if any
+} //end struct
+
+//extCommand is the reply to a "step" message
+type extCommand struct {
+	Voltages []float64 `json:"voltages"` //voltages for the first and second joints
+} //end struct
+
+//externalController steps in lockstep with a controller in another process
+type externalController struct {
+	arm2   Arm2          //arm being controlled, held up if the outside controller fails
+	out    io.Writer     //where messages are written
+	in     *bufio.Reader //where commands are read from
+	closer io.Closer     //closes the connection, nil if there is nothing to close
+	proc   *exec.Cmd     //process running the controller, nil if it connected over TCP
+	err    error         //first error talking to the controller
+} //end struct
+
+//Create a controller that talks over a reader and writer
+//Arm2 a2 - arm being controlled
+//io.Reader r - where commands are read from
+//io.Writer w - where messages are written
+//io.Closer c - closes the connection, can be nil
+func newExternalController(a2 Arm2, r io.Reader, w io.Writer, c io.Closer) *externalController {
+	return &externalController{arm2: a2, out: w, in: bufio.NewReader(r), closer: c}
+} //end newExternalController
+
+//Start a controller process and talk to it over its stdin and stdout
+//Arm2 a2 - arm being controlled
+//string command - command line to run, such as "python3 controller.py"
+//return - the controller, or an error if the process could not start
+func startProcessController(a2 Arm2, command string) (*externalController, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no command to run")
+	} //if
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr //let the controller print its own messages
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	} //if
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	} //if
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	} //if
+
+	c := newExternalController(a2, r, w, w)
+	c.proc = cmd
+	return c, nil
+} //end startProcessController
+
+//Wait for a controller to connect over TCP
+//Arm2 a2 - arm being controlled
+//string address - local address to listen on, such as "127.0.0.1:5800"
+//return - the controller, or an error if nothing could connect
+func listenForController(a2 Arm2, address string) (*externalController, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	} //if
+	defer listener.Close()
+
+	fmt.Println("waiting for a controller on", listener.Addr())
+	conn, err := listener.Accept()
+	if err != nil {
+		return nil, err
+	} //if
+
+	return newExternalController(a2, conn, conn, conn), nil
+} //end listenForController
+
+//Send the state and wait for the outside controller's voltages
+//ArmState state - measured state of the arm
+//ArmState goal - state to drive the arm to
+//float64 t - simulation time in seconds
+//return - voltages for the first and second joints
+func (c *externalController) calculate(state, goal ArmState, t float64) (float64, float64) {
+	if c.err == nil {
+		c.send("step", state, goal, t)
+	} //if
+	if c.err == nil {
+		var cmd extCommand
+		line, err := c.in.ReadBytes('\n')
+		if err == nil {
+			err = json.Unmarshal(line, &cmd)
+		} //if
+		if err == nil && len(cmd.Voltages) != 2 { //a missing voltage isn't 0V
+			err = fmt.Errorf("reply %q needs a voltage for each joint", strings.TrimSpace(string(line)))
+		} //if
+		if err == nil {
+			return cmd.Voltages[0], cmd.Voltages[1]
+		} //if
+		c.fail(err)
+	} //if
+
+	//nothing to follow, so only hold the arm up
+	return calcFFArm2(c.arm2, 0, 0, 0, 0)
+} //end calculate
+
+//Tell the outside controller there is a new goal
+//ArmState state - measured state of the arm
+//ArmState goal - state to drive the arm to
+//float64 t - simulation time in seconds
+func (c *externalController) reset(state, goal ArmState, t float64) {
+	if c.err == nil {
+		c.send("reset", state, goal, t)
+	} //if
+} //end reset
+
+//Write a message as one line of JSON
+//string kind - type of the message
+//ArmState state - measured state of the arm
+//ArmState goal - state to drive the arm to
+//float64 t - simulation time in seconds
+func (c *externalController) send(kind string, state, goal ArmState, t float64) {
+	cond := c.arm2.calcConditioning(state[0], state[2])
+	line, err := json.Marshal(extMessage{kind, t, state, goal, cond.manipulability, 1 / cond.condition, cond.near.String()})
+	if err == nil {
+		_, err = c.out.Write(append(line, '\n'))
+	} //if
+	if err != nil {
+		c.fail(err)
+	} //if
+} //end send
+
+//Stop talking to the outside controller after an error
+//error err - what went wrong
+func (c *externalController) fail(err error) {
+	c.err = err
+	fmt.Println("external controller:", err, "- holding the arm")
+	c.close()
+} //end fail
+
+//Close the connection to the outside controller, stopping its process and waiting for it to exit
+func (c *externalController) close() {
+	if c.closer != nil {
+		c.closer.Close()
+		c.closer = nil
+	} //if
+	if c.proc != nil {
+		c.proc.Process.Kill()
+		c.proc.Wait() //reap the process
+		c.proc = nil
+	} //if
+} //end close
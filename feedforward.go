@@ -0,0 +1,60 @@
+//feedforward
+//Created on: 10/19/2026
+//Feedforward models for holding up and driving the joints of the arm
+
+package main
+
+import (
+	"math"
+)
+
+//ffGains are the optional feedforward terms added on top of the static holding voltage
+type ffGains struct {
+	kS float64 //voltage to overcome static friction
+	kV float64 //voltage per radian/second of joint velocity
+	kA float64 //voltage per radian/second^2 of joint acceleration
+} //end struct
+
+//Calculate the feedforward gains of an arm from its motor model
+//*Arm a - arm to calculate the gains for
+//float64 kS - voltage to overcome static friction
+//return - the feedforward gains for the arm
+func motorFFGains(a *Arm, kS float64) ffGains {
+	kV := a.gearRatio / a.motor.kV                             //back-EMF of the motors spinning at the joint velocity
+	kA := (a.moi * a.motor.kResistance) / (a.kT * a.gearRatio) //voltage to accelerate the moment of inertia
+	return ffGains{kS, kV, kA}
+} //end motorFFGains
+
+//Convert a torque at the joint to the voltage that produces it with the arm at rest
+//float64 torque - torque at the joint in Nm
+//return - voltage to apply to the motors
+func (a Arm) torqueToVoltage(torque float64) float64 {
+	return (torque * a.motor.kResistance) / (a.kT * a.gearRatio)
+} //end torqueToVoltage
+
+//Calculate the feedforward voltage for the arm
+//float64 torque - static torque to hold at the joint in Nm
+//float64 vel - desired velocity of the joint in radians/second
+//float64 acc - desired acceleration of the joint in radians/second^2
+//return - the feedforward voltage
+func (a Arm) calcFF(torque, vel, acc float64) float64 {
+	ff := a.torqueToVoltage(torque) + a.ff.kV*vel + a.ff.kA*acc
+
+	if vel != 0 { //static friction only opposes motion
+		ff += math.Copysign(a.ff.kS, vel)
+	} //if
+
+	return ff
+} //end calcFF
+
+//calculate the voltages required to hold up and drive both joints of a two-jointed arm
+//Arm2 a2 - arm to hold up
+//float64 vel1 - desired velocity of the first joint
+//float64 vel2 - desired velocity of the second joint
+//float64 acc1 - desired acceleration of the first joint
+//float64 acc2 - desired acceleration of the second joint
+//return - feedforward voltages for the first and second joints
+func calcFFArm2(a2 Arm2, vel1, vel2, acc1, acc2 float64) (float64, float64) {
+	tau1, tau2 := a2.calcGravTorques(a2.arm1.angle, a2.arm2.angle)
+	return a2.arm1.calcFF(tau1, vel1, acc1), a2.arm2.calcFF(tau2, vel2, acc2)
+} //end calcFFArm2
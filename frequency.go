@@ -0,0 +1,306 @@
+//frequency
+//Created on: 10/19/2026
+//Frequency response of a joint from sine sweeps, with its bandwidth and stability margins
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/cmplx"
+	"os"
+)
+
+//frequencyPoint is the response of a joint at one frequency
+type frequencyPoint struct {
+	freq   float64    //frequency in Hz
+	plant  complex128 //angle per Volt of the joint and its motor
+	closed complex128 //angle per angle of setpoint with PID closing the loop
+	open   complex128 //loop gain of the PID, the gravity feedforward and the plant together
+} //end struct
+
+//frequencyResponse is a joint's response over a range of frequencies
+type frequencyResponse struct {
+	points      []frequencyPoint //response at each frequency, lowest first
+	bandwidth   float64          //frequency the closed loop drops 3dB in Hz, NaN past the sweep
+	crossover   float64          //frequency the open loop has a gain of one in Hz, NaN if it does not
+	phaseMargin float64          //phase above -180 degrees at the crossover in degrees
+	gainMargin  float64          //gain below one where the open loop phase is -180 degrees in dB
+	delayMargin float64          //extra latency the loop can take before going unstable in seconds
+} //end struct
+
+//header for the CSV written by writeBode
+const bodeHeader = "freq,plantMag,plantPhase,closedMag,closedPhase,openMag,openPhase"
+
+//Space frequencies evenly on a log scale
+//float64 min - lowest frequency in Hz
+//float64 max - highest frequency in Hz
+//int n - number of frequencies
+//return - the frequencies, lowest first
+func logFrequencies(min, max float64, n int) []float64 {
+	freqs := make([]float64, n)
+	for i := range freqs {
+		freqs[i] = min * math.Pow(max/min, float64(i)/float64(n-1))
+	} //loop
+	return freqs
+} //end logFrequencies
+
+//sineRecord is what a joint did while a voltage was swept into it
+type sineRecord struct {
+	times    []float64 //time of each sample in seconds
+	injected []float64 //voltage added to the joint's input
+	angle    []float64 //angle away from the operating angle in radians
+	voltage  []float64 //voltage applied away from the holding voltage
+	pid      []float64 //part of the voltage from the PID
+} //end struct
+
+//Sweep a voltage into a joint's input around an operating angle while PID and the chain feedforward hold the arm there
+//int joint - joint being swept, 1 or 2
+//ArmState setpoint - state both joints operate at
+//func excite - voltage added to the joint at a time
+//float64 duration - length of the sweep in seconds
+//return - the recorded sweep
+func simulateSweep(joint int, setpoint ArmState, excite func(t float64) float64, duration float64) sineRecord {
+	a2 := makeArm2()
+	a2.arm1.angle, a2.arm2.angle = setpoint[0], setpoint[2]
+	a2.update()
+
+	arm, i := a2.arm1, 0
+	if joint == 2 {
+		arm, i = a2.arm2, 2
+	} //if
+
+	//voltage that holds the arm at the operating angles
+	hold1, hold2 := calcFFArm2(a2, 0, 0, 0, 0)
+	hold := hold1
+	if joint == 2 {
+		hold = hold2
+	} //if
+
+	c := &pidFFController{arm2: a2}
+	var r sineRecord
+	for t := 0.0; t < duration; t += dt {
+		a2.arm1.voltage, a2.arm2.voltage = c.calculate(a2.getState(), setpoint, t)
+		ff1, ff2 := calcFFArm2(a2, 0, 0, 0, 0) //the feedforward the controller added
+		ff := ff1
+		if joint == 2 {
+			ff = ff2
+		} //if
+		pid := arm.voltage - ff
+		arm.voltage += excite(t)
+
+		//the angle the voltage was calculated from, and the voltage held over the next step
+		r.times = append(r.times, t)
+		r.injected = append(r.injected, excite(t))
+		r.angle = append(r.angle, arm.angle-setpoint[i])
+		r.voltage = append(r.voltage, OutputClamp(arm.voltage, -MaxVoltage, MaxVoltage)-hold)
+		r.pid = append(r.pid, pid)
+
+		a2.arm1.update()
+		a2.arm2.update()
+		a2.update()
+	} //loop
+
+	return r
+} //end simulateSweep
+
+//Find the component of a signal at a frequency
+//[]float64 times - time of each sample in seconds
+//[]float64 values - signal at each sample
+//float64 w - frequency in radians/second
+//return - the signal's phasor at the frequency, scaled by the number of samples
+func fourierAt(times, values []float64, w float64) complex128 {
+	var sum complex128
+	for i, v := range values {
+		sum += complex(v, 0) * cmplx.Exp(complex(0, -w*times[i]))
+	} //loop
+	return sum
+} //end fourierAt
+
+//Measure the response at one frequency from a sweep
+//sineRecord r - recorded sweep
+//int from - first sample to use
+//float64 freq - frequency in Hz
+//return - the response at the frequency
+func (r sineRecord) responseAt(from int, freq float64) frequencyPoint {
+	w := 2 * math.Pi * freq
+	ts := r.times[from:]
+	d := fourierAt(ts, r.injected[from:], w)
+	y := fourierAt(ts, r.angle[from:], w)
+	u := fourierAt(ts, r.voltage[from:], w)
+	c := fourierAt(ts, r.pid[from:], w)
+
+	//what comes back around the loop against what goes into the plant, with the gravity feedforward
+	//following the angle as part of the loop; the setpoint only passes through the PID, so y/r = -c/d
+	return frequencyPoint{freq: freq, plant: y / u, closed: -c / d, open: -(u - d) / u}
+} //end responseAt
+
+//Measure the frequency response one sine at a time, waiting for each to settle
+//int joint - joint to measure, 1 or 2
+//ArmState setpoint - state both joints operate at
+//float64 amplitude - size of the sine in Volts
+//[]float64 freqs - frequencies to measure at in Hz
+//return - the response at each frequency
+func steppedSineResponse(joint int, setpoint ArmState, amplitude float64, freqs []float64) []frequencyPoint {
+	points := make([]frequencyPoint, len(freqs))
+	for i, f := range freqs {
+		//settle for a few periods, then measure a whole number of periods of at least two seconds
+		period := 1 / f
+		settle := math.Max(3*period, 2)
+		measure := math.Ceil(2/period) * period
+
+		r := simulateSweep(joint, setpoint, func(t float64) float64 {
+			return amplitude * math.Sin(2*math.Pi*f*t)
+		}, settle+measure)
+		points[i] = r.responseAt(int(settle/dt), f)
+	} //loop
+	return points
+} //end steppedSineResponse
+
+//Measure the frequency response with one exponential chirp through all the frequencies
+//int joint - joint to measure, 1 or 2
+//ArmState setpoint - state both joints operate at
+//float64 amplitude - size of the chirp in Volts
+//[]float64 freqs - frequencies to measure at in Hz, lowest first
+//float64 duration - length of the chirp in seconds
+//return - the response at each frequency
+func chirpResponse(joint int, setpoint ArmState, amplitude float64, freqs []float64, duration float64) []frequencyPoint {
+	f0, f1 := freqs[0], freqs[len(freqs)-1]
+	k := math.Log(f1 / f0)
+
+	r := simulateSweep(joint, setpoint, func(t float64) float64 {
+		phase := 2 * math.Pi * f0 * duration / k * (math.Exp(k*t/duration) - 1) //integral of the frequency
+		return amplitude * math.Sin(phase)
+	}, duration)
+
+	points := make([]frequencyPoint, len(freqs))
+	for i, f := range freqs {
+		points[i] = r.responseAt(0, f)
+	} //loop
+	return points
+} //end chirpResponse
+
+//Convert a gain to decibels
+//complex128 h - response to take the gain of
+func toDecibels(h complex128) float64 {
+	return 20 * math.Log10(cmplx.Abs(h))
+} //end toDecibels
+
+//Get the phases of a response, unwrapped so they do not jump by 360 degrees
+//[]frequencyPoint points - response at each frequency
+//func pick - part of the response to take the phase of
+//return - phase at each frequency in degrees
+func unwrapPhase(points []frequencyPoint, pick func(p frequencyPoint) complex128) []float64 {
+	phases := make([]float64, len(points))
+	for i, p := range points {
+		phases[i] = ToDegrees(cmplx.Phase(pick(p)))
+		if i > 0 { //closest to the last phase
+			phases[i] -= 360 * math.Round((phases[i]-phases[i-1])/360)
+		} //if
+	} //loop
+	return phases
+} //end unwrapPhase
+
+//Find where a value sampled at each frequency first crosses a level, interpolating on a log scale
+//[]float64 freqs - frequencies in Hz
+//[]float64 values - value at each frequency
+//float64 level - value to cross
+//return - the frequency it crosses at, NaN if it never does, the index of the sample after, and the fraction between the samples
+func findCrossing(freqs, values []float64, level float64) (float64, int, float64) {
+	for i := 1; i < len(values); i++ {
+		a, b := values[i-1]-level, values[i]-level
+		if a == 0 || a*b < 0 {
+			u := a / (a - b)
+			return freqs[i-1] * math.Pow(freqs[i]/freqs[i-1], u), i, u
+		} //if
+	} //loop
+	return math.NaN(), 0, 0
+} //end findCrossing
+
+//Calculate the bandwidth and stability margins of a response
+//[]frequencyPoint points - response at each frequency, lowest first
+//return - the response with its bandwidth and margins
+func analyzeResponse(points []frequencyPoint) frequencyResponse {
+	resp := frequencyResponse{points: points, phaseMargin: math.Inf(1), gainMargin: math.Inf(1), delayMargin: math.Inf(1)}
+	freqs := make([]float64, len(points))
+	closedMag := make([]float64, len(points))
+	openMag := make([]float64, len(points))
+	for i, p := range points {
+		freqs[i] = p.freq
+		closedMag[i] = toDecibels(p.closed)
+		openMag[i] = toDecibels(p.open)
+	} //loop
+	openPhase := unwrapPhase(points, func(p frequencyPoint) complex128 { return p.open })
+
+	resp.bandwidth, _, _ = findCrossing(freqs, closedMag, -3)
+
+	//phase margin where the open loop gain is one
+	var i int
+	var u float64
+	resp.crossover, i, u = findCrossing(freqs, openMag, 0)
+	if !math.IsNaN(resp.crossover) {
+		resp.phaseMargin = 180 + openPhase[i-1] + u*(openPhase[i]-openPhase[i-1])
+		resp.delayMargin = ToRadians(resp.phaseMargin) / (2 * math.Pi * resp.crossover)
+	} //if
+
+	//gain margin where the open loop phase is -180 degrees
+	if f, i, u := findCrossing(freqs, openPhase, -180); !math.IsNaN(f) {
+		resp.gainMargin = -(openMag[i-1] + u*(openMag[i]-openMag[i-1]))
+	} //if
+
+	return resp
+} //end analyzeResponse
+
+//Write the response as CSV, one frequency per line with gains in dB and phases in degrees
+//io.Writer w - where to write the response
+//frequencyResponse resp - response to write
+func writeBode(w io.Writer, resp frequencyResponse) {
+	plant := unwrapPhase(resp.points, func(p frequencyPoint) complex128 { return p.plant })
+	closed := unwrapPhase(resp.points, func(p frequencyPoint) complex128 { return p.closed })
+	open := unwrapPhase(resp.points, func(p frequencyPoint) complex128 { return p.open })
+
+	fmt.Fprintln(w, bodeHeader)
+	for i, p := range resp.points {
+		fmt.Fprintf(w, "%f,%f,%f,%f,%f,%f,%f\n", p.freq,
+			toDecibels(p.plant), plant[i], toDecibels(p.closed), closed[i], toDecibels(p.open), open[i])
+	} //loop
+} //end writeBode
+
+//Get a string representation of the bandwidth and margins
+func (resp frequencyResponse) String() string {
+	return fmt.Sprintf("bandwidth %.2f Hz, crossover %.2f Hz, phase margin %.1f deg, gain margin %.1f dB, delay margin %.1f ms",
+		resp.bandwidth, resp.crossover, resp.phaseMargin, resp.gainMargin, resp.delayMargin*1000)
+} //end String
+
+//Measure the frequency response of a joint, print its margins and write it to a CSV
+//int joint - joint to measure, 1 or 2
+//string method - stepped or chirp
+//float64 amplitude - size of the sweep in Volts
+//string path - CSV file to write to
+func runFrequencyResponse(joint int, method string, amplitude float64, path string) {
+	setpoint := ArmState{ToRadians(30), 0, ToRadians(30), 0}
+	freqs := logFrequencies(0.1, 10, 30) //well under the 25Hz the loop can see
+
+	var points []frequencyPoint
+	switch method {
+	case "stepped":
+		points = steppedSineResponse(joint, setpoint, amplitude, freqs)
+	case "chirp":
+		points = chirpResponse(joint, setpoint, amplitude, freqs, 60)
+	default:
+		fmt.Println("unknown sweep method", method, "- use stepped or chirp")
+		return
+	} //switch
+
+	resp := analyzeResponse(points)
+	fmt.Printf("joint %d swept with %s: %v\n", joint, method, resp)
+
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Println("could not create bode file:", err)
+		return
+	} //if
+	defer f.Close()
+	writeBode(f, resp)
+} //end runFrequencyResponse
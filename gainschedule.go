@@ -0,0 +1,89 @@
+//gainschedule
+//Created on: 10/19/2026
+//Tables of PID gains keyed on the configuration of the arm
+
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+//gainEntry is the PID gains to use at one value of the scheduling variable
+type gainEntry struct {
+	at float64 //value of the scheduling variable
+	kP float64 //proportionality constant
+	kI float64 //integral constant
+	kD float64 //derivative constant
+} //end struct
+
+//gainSchedule interpolates PID gains from a table keyed on a configuration variable
+type gainSchedule struct {
+	key     func(a2 Arm2) float64 //configuration variable the table is keyed on
+	entries []gainEntry           //gains in order of the scheduling variable
+} //end struct
+
+//Create a gain schedule
+//func key - calculates the scheduling variable from the arm
+//...gainEntry entries - gains at values of the scheduling variable, in any order
+func newGainSchedule(key func(a2 Arm2) float64, entries ...gainEntry) *gainSchedule {
+	sorted := append([]gainEntry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].at < sorted[j].at })
+	return &gainSchedule{key, sorted}
+} //end newGainSchedule
+
+//Look up the gains at a value of the scheduling variable
+//float64 x - value of the scheduling variable
+//return - gains linearly interpolated between the nearest entries, held at the ends of the table
+func (gs gainSchedule) lookup(x float64) gainEntry {
+	n := len(gs.entries)
+	if n == 0 {
+		return gainEntry{at: x}
+	} //if
+	if x <= gs.entries[0].at {
+		return gs.entries[0]
+	} //if
+	if x >= gs.entries[n-1].at {
+		return gs.entries[n-1]
+	} //if
+
+	//first entry past x
+	i := sort.Search(n, func(i int) bool { return gs.entries[i].at > x })
+	lo, hi := gs.entries[i-1], gs.entries[i]
+	f := (x - lo.at) / (hi.at - lo.at)
+
+	return gainEntry{x, lerp(lo.kP, hi.kP, f), lerp(lo.kI, hi.kI, f), lerp(lo.kD, hi.kD, f)}
+} //end lookup
+
+//Linearly interpolate between two values
+//float64 a - value at f = 0
+//float64 b - value at f = 1
+//float64 f - fraction of the way from a to b
+func lerp(a, b, f float64) float64 {
+	return a + (b-a)*f
+} //end lerp
+
+//Update the position PID gains of both joints from their schedules, called every step the PIDs are used
+//the velocity and current loops keep fixed gains, and LQR and MPC derive theirs from the model at each goal
+func (a2 Arm2) scheduleGains() {
+	for _, arm := range []*Arm{a2.arm1, a2.arm2} {
+		if arm.schedule != nil {
+			gains := arm.schedule.lookup(arm.schedule.key(a2))
+			arm.pid.kP, arm.pid.kI, arm.pid.kD = gains.kP, gains.kI, gains.kD
+		} //if
+	} //loop
+} //end scheduleGains
+
+//KEYS
+
+//Get how far the elbow is folded, 0 when extended and pi when folded back on itself
+//Arm2 a2 - arm to get the variable of
+func elbowFoldKey(a2 Arm2) float64 {
+	return math.Abs(math.Remainder(a2.arm2.angle, 2*math.Pi))
+} //end elbowFoldKey
+
+//Get the mass held at the end of the arm in kg
+//Arm2 a2 - arm to get the variable of
+func payloadKey(a2 Arm2) float64 {
+	return a2.payload
+} //end payloadKey
@@ -71,6 +71,20 @@ func drawPoint(ctx *canvas.Context, p Point, r float64) {
 	ctx.Pop()
 } //end drawPoint
 
+//draw the angle a goal is approached at as a line back from the point
+//ctx *canvas.Context - responsible for drawing
+//Goal goal - oriented goal to draw
+func drawHeading(ctx *canvas.Context, goal Goal) {
+	ctx.Push()
+
+	x, y := goal.point.x*pixelToMeters+float64(width)/2, goal.point.y*pixelToMeters
+	ctx.SetLineWidth(6)
+	ctx.DrawLine(x, y, x-60*math.Cos(goal.heading), y-60*math.Sin(goal.heading))
+	ctx.Stroke()
+
+	ctx.Pop()
+} //end drawHeading
+
 //draw the robot to the display
 //ctx *canvas.Context - responsible for drawing
 func drawArm(ctx *canvas.Context) {
@@ -111,12 +125,26 @@ func drawArm2(ctx *canvas.Context) {
 		robotArm2.arm2.get2JEndPtPxl(robotArm2.arm1.angle).x, robotArm2.arm2.get2JEndPtPxl(robotArm2.arm1.angle).y)
 	ctx.Stroke() //draw the line
 
+	if robotArm2.arm3 != nil { //the claw on the wrist
+		colors = robotArm2.arm3.getColor(0)
+		ctx.SetRGB255(colors[0], colors[1], colors[2])
+		ctx.SetLineWidth(armWidth / 2)
+		ctx.DrawLine(robotArm2.arm3.start.x, robotArm2.arm3.start.y,
+			robotArm2.arm3.get2JEndPtPxl(robotArm2.arm3.parentAngle).x, robotArm2.arm3.get2JEndPtPxl(robotArm2.arm3.parentAngle).y)
+		ctx.Stroke()
+	} //if
+
 	ctx.Pop() //load last saved state
 } //end drawArm2
 
 //draw the configuration space of the arm
 //ctx *canvas.Context - responsible for drawing
 func drawCSpace(ctx *canvas.Context) {
+	if reach != nil { //limits or obstacles cut into the annulus
+		drawWorkspace(ctx, reach)
+		return
+	} //if
+
 	ctx.Push()
 
 	ctx.SetRGBA(cspaceColor[0], cspaceColor[1], cspaceColor[2], cspaceColor[3])                      //c-space color
@@ -130,6 +158,34 @@ func drawCSpace(ctx *canvas.Context) {
 	ctx.Pop()
 } //end drawCSpace
 
+//draw the sampled region the arm can reach and the obstacles in the way
+//ctx *canvas.Context - responsible for drawing
+//*workspace ws - region the arm can reach
+func drawWorkspace(ctx *canvas.Context, ws *workspace) {
+	ctx.Push()
+
+	size := ws.cellSize * pixelToMeters
+	ctx.SetRGBA(cspaceColor[0], cspaceColor[1], cspaceColor[2], cspaceColor[3]) //c-space color
+	for key := range ws.cells {
+		ctx.DrawRectangle(float64(key[0])*size+float64(width)/2, float64(key[1])*size, size, size)
+	} //loop
+	ctx.Fill()
+
+	ctx.SetRGBA(0.5, 0.5, 0.5, 0.75) //grey obstacles
+	for _, o := range ws.obstacles {
+		switch o := o.(type) {
+		case circleObstacle:
+			ctx.DrawCircle(o.center.x*pixelToMeters+float64(width)/2, o.center.y*pixelToMeters, o.radius*pixelToMeters)
+			ctx.Fill()
+		case floorObstacle:
+			ctx.DrawRectangle(0, o.height*pixelToMeters, float64(width), -float64(height))
+			ctx.Fill()
+		} //switch
+	} //loop
+
+	ctx.Pop()
+} //end drawWorkspace
+
 //Draw a point around where the mouse is to show where the potential goal would be
 //*canvas.Context ctx - responsible for drawing
 func drawGhost(ctx *canvas.Context) {
@@ -163,7 +219,10 @@ func drawPoints(ctx *canvas.Context) {
 		// val := float64(255 - factor*(i-start))
 		val := 1.0 - (factor * float64((i - start)))
 		ctx.SetRGBA(1, 0, 0, val)
-		drawPoint(ctx, pts[i], 30)
+		drawPoint(ctx, pts[i].point, 30)
+		if pts[i].oriented { //line the claw approaches along
+			drawHeading(ctx, pts[i])
+		} //if
 	} //loop
 } //end drawPoints
 
@@ -186,7 +245,19 @@ func displayData(ctx *canvas.Context) {
 	case finished:
 		ctx.SetColor(colornames.Blue)
 		break
-	case testing:
+	case commanding:
+		ctx.SetColor(colornames.Purple)
+		ctx.DrawString("j1 "+armloop.commands[0].mode.String()+": "+fmt.Sprintf("%.2f", armloop.commands[0].value), 100, 100)
+		ctx.DrawString("j2 "+armloop.commands[1].mode.String()+": "+fmt.Sprintf("%.2f", armloop.commands[1].value), 100, 200)
+		break
+	case cartesianRate:
+		ctx.SetColor(colornames.Cyan)
+		break
+	case unreachable:
+		ctx.SetColor(colornames.Red)
+		ctx.DrawString(armloop.err.Error(), 100, 100)
+		break
+	case physicsTesting:
 		ctx.SetColor(colornames.White)
 		ctx.DrawString("cosine of j2 angle: "+fmt.Sprintf("%f", math.Cos(robotArm2.arm2.angle+robotArm2.arm2.parentAngle)), 100, 100)
 		ctx.DrawString("gravity torque: "+fmt.Sprintf("%f", robotArm2.arm2.calcGravTorque()), 100, 200)
@@ -194,10 +265,27 @@ func displayData(ctx *canvas.Context) {
 		ctx.DrawString("j2 vel: "+fmt.Sprintf("%f", robotArm2.arm2.vel), 100, 500)
 	} //switch
 	ctx.DrawString(armloop.state.String(), 1400, 200)
+	ctx.DrawString(armloop.controllerName, 1400, 300)
+
+	//rules for arriving at the goal and when they were met
+	if armloop.state == goalTracking || armloop.state == finished {
+		y := 500.0
+		for r := jointRule; r < numArrivalRules; r++ {
+			if !armloop.criteria.uses(r) {
+				continue
+			} //if
+			met := "-"
+			if at := armloop.tracker.metAt[r]; at >= 0 {
+				met = fmt.Sprintf("%.2fs", at)
+			} //if
+			ctx.DrawString(r.String()+": "+met, 1400, y)
+			y += 50
+		} //loop
+	} //if
 
 	ctx.InvertY()
 
-	if armloop.state == testing {
+	if armloop.state == physicsTesting {
 		displayPointCoords(ctx, robotArm2.arm2.get2JEndPtM(robotArm2.arm2.parentAngle), 100, 300)
 		displayPointCoords(ctx, midpoint, 100, 600)
 		ctx.SetColor(colornames.Red)
@@ -209,3 +297,131 @@ func displayData(ctx *canvas.Context) {
 
 	ctx.Pop()
 } //end displayData
+
+//Draw the surface the arm can press against and how hard it is being pressed
+//*canvas.Context ctx - responsible for drawing
+func drawSurface(ctx *canvas.Context) {
+	s := robotArm2.surface
+	if s == nil {
+		return
+	} //if
+
+	ctx.Push()
+
+	//a long line along the surface, which is perpendicular to its normal
+	along := scalePoint(Point{-s.normal.y, s.normal.x}, 10)
+	ctx.SetColor(colornames.Orange)
+	ctx.SetLineWidth(4)
+	ctx.DrawLine((s.point.x-along.x)*pixelToMeters+float64(width)/2, (s.point.y-along.y)*pixelToMeters,
+		(s.point.x+along.x)*pixelToMeters+float64(width)/2, (s.point.y+along.y)*pixelToMeters)
+	ctx.Stroke()
+
+	//contact force
+	force := robotArm2.calcExternalForce()
+	drawFloat(ctx, math.Hypot(force.x, force.y), 1400, 400, "Force (N)")
+
+	ctx.Pop()
+} //end drawSurface
+
+//Draw the path planned to the current goal, as the end point follows it
+//*canvas.Context ctx - responsible for drawing
+func drawPath(ctx *canvas.Context) {
+	if armloop.state != goalTracking || len(armloop.path) < 2 {
+		return
+	} //if
+
+	ctx.Push()
+
+	ctx.SetColor(colornames.Cyan)
+	ctx.SetLineWidth(3)
+	for i, q := range armloop.path {
+		p := robotArm2.calcEndPoint(q.q1, q.q2)
+		x, y := p.x*pixelToMeters+float64(width)/2, p.y*pixelToMeters
+		if i == 0 {
+			ctx.MoveTo(x, y)
+		} else {
+			ctx.LineTo(x, y)
+		} //if
+	} //loop
+	ctx.Stroke()
+
+	ctx.Pop()
+} //end drawPath
+
+//Draw the order the queued points will be visited in, numbered from where the arm is headed, and the predicted time to visit them
+//*canvas.Context ctx - responsible for drawing
+func drawOrder(ctx *canvas.Context) {
+	first := orderIndex()
+	if reorder == nil || first >= len(pts) {
+		return
+	} //if
+
+	ctx.Push()
+
+	//from the point before, or the end of the arm if it isn't headed anywhere
+	from := robotArm2.calcEndPoint(robotArm2.arm1.angle, robotArm2.arm2.angle)
+	if first > pointIndex {
+		from = pts[first-1].point
+	} //if
+	ctx.SetColor(colornames.Orange)
+	ctx.SetLineWidth(3)
+	ctx.MoveTo(from.x*pixelToMeters+float64(width)/2, from.y*pixelToMeters)
+	for _, g := range pts[first:] {
+		ctx.LineTo(g.point.x*pixelToMeters+float64(width)/2, g.point.y*pixelToMeters)
+	} //loop
+	ctx.Stroke()
+
+	//number each point beside it
+	ctx.InvertY() //flip y value to draw the strings
+	for i, g := range pts[first:] {
+		ctx.DrawString(strconv.Itoa(i+1), g.point.x*pixelToMeters+float64(width)/2+35, float64(height)-g.point.y*pixelToMeters)
+	} //loop
+	ctx.InvertY()
+
+	drawFloat(ctx, cycleTime, 1400, 950, "Cycle time (s)")
+	hint := "type go to run"
+	if reordering {
+		hint = "ordering..."
+	} //if
+	ctx.InvertY()
+	ctx.DrawString(hint, 1400, 900)
+	ctx.InvertY()
+
+	ctx.Pop()
+} //end drawOrder
+
+//Draw the manipulability ellipse at the end of the arm, and how well conditioned the arm is
+//*canvas.Context ctx - responsible for drawing
+func drawConditioning(ctx *canvas.Context) {
+	const scale = 0.25 //meters drawn per meter/radian of the ellipse
+
+	q1, q2 := robotArm2.arm1.angle, robotArm2.arm2.angle
+	cond := robotArm2.calcConditioning(q1, q2)
+	tip := robotArm2.calcEndPoint(q1, q2)
+	x, y := tip.x*pixelToMeters+float64(width)/2, tip.y*pixelToMeters
+
+	ctx.Push()
+
+	//ellipse along its principal axes, red near a singularity
+	ctx.SetRGBA(0, 1, 1, 0.5)
+	if cond.near != notSingular {
+		ctx.SetRGBA(1, 0, 0, 0.5)
+	} //if
+	ctx.RotateAbout(math.Atan2(cond.axes[0].y, cond.axes[0].x), x, y)
+	ctx.DrawEllipse(x, y, math.Hypot(cond.axes[0].x, cond.axes[0].y)*scale*pixelToMeters,
+		math.Max(math.Hypot(cond.axes[1].x, cond.axes[1].y)*scale*pixelToMeters, 1))
+	ctx.SetLineWidth(3)
+	ctx.Stroke()
+
+	ctx.Pop()
+
+	drawFloat(ctx, cond.manipulability, 1400, 800, "Manipulability")
+	drawFloat(ctx, cond.condition, 1400, 850, "Condition")
+	if cond.near != notSingular {
+		ctx.Push()
+		ctx.InvertY()
+		ctx.SetColor(colornames.Red)
+		ctx.DrawString("near singularity: "+cond.near.String(), 1400, 900)
+		ctx.Pop()
+	} //if
+} //end drawConditioning
@@ -0,0 +1,258 @@
+//ik
+//Created on: 10/19/2026
+//Every inverse kinematics solution of the arm and the policies for picking one
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+//ikSolution is a pair of joint angles that puts the end of the arm on a point
+type ikSolution struct {
+	q1 float64 //angle of the first joint in radians
+	q2 float64 //angle of the second joint relative to the first in radians
+} //end struct
+
+//ikPolicy decides which solution the arm moves to
+type ikPolicy int
+
+const (
+	closestIK   ikPolicy = iota //closest to the current joint angles
+	elbowUpIK                   //elbow above the other solution's
+	elbowDownIK                 //elbow below the other solution's
+	minTravelIK                 //shortest move for the slower joint
+)
+
+//get a string representation of the policy
+func (p ikPolicy) String() string {
+	return [...]string{"closest", "elbowup", "elbowdown", "mintravel"}[p]
+} //end String
+
+//Find a policy by name
+//string name - name of the policy
+//return - the policy, or an error if there is none with the name
+func parseIKPolicy(name string) (ikPolicy, error) {
+	for p := closestIK; p <= minTravelIK; p++ {
+		if p.String() == name {
+			return p, nil
+		} //if
+	} //loop
+	return closestIK, fmt.Errorf("no IK policy named %q", name)
+} //end parseIKPolicy
+
+//reachability is whether the arm can reach a point, and why not if it can't
+type reachability int
+
+const (
+	reachable     reachability = iota //at least one solution reaches the point
+	tooFar                            //past the arm stretched straight out
+	tooClose                          //inside the arm folded back on itself
+	outsideLimits                     //only reached by angles past the joint limits
+	singular                          //only reached with the arm straight or folded, where it can't move in or out
+)
+
+//get a string representation of the reachability
+func (r reachability) String() string {
+	return [...]string{"reachable", "too far", "too close", "outside the joint limits", "singular"}[r]
+} //end String
+
+//ikError is why the arm can't reach a goal and the closest it can get
+type ikError struct {
+	reach    reachability //why the goal can't be reached
+	goal     Point        //point that was asked for
+	nearest  Point        //closest point that can be reached
+	fallback ikSolution   //solution that reaches the nearest point
+} //end struct
+
+//get a string representation of the error
+func (e *ikError) Error() string {
+	return fmt.Sprintf("(%.3f, %.3f) is %v, nearest reachable is (%.3f, %.3f)",
+		e.goal.x, e.goal.y, e.reach, e.nearest.x, e.nearest.y)
+} //end Error
+
+//Check if a point is within the reach of the arm, ignoring joint limits
+//Point p - point to check
+//float64 l1 - length of first joint
+//float64 l2 - length of second joint
+//return - whether the point is reachable, singular, too far or too close
+func checkReach(p Point, l1, l2 float64) reachability {
+	const tolerance = 1e-9 //distance from the edge of the reach that counts as on it
+	r := math.Hypot(p.x, p.y)
+	outer, inner := l1+l2, math.Abs(l1-l2)
+
+	switch {
+	case r > outer+tolerance:
+		return tooFar
+	case r < inner-tolerance:
+		return tooClose
+	case r > outer-tolerance || r < inner+tolerance:
+		return singular
+	} //switch
+	return reachable
+} //end checkReach
+
+//Find the closest point to another the arm can reach, keeping clear of the singular edges
+//Point p - point to move within reach
+//float64 l1 - length of first joint
+//float64 l2 - length of second joint
+//return - the point if it is reachable, otherwise the closest reachable point in the same direction
+func nearestReachable(p Point, l1, l2 float64) Point {
+	const margin = 1e-3 //distance to stay inside the edges of the reach
+	r := math.Hypot(p.x, p.y)
+	clamped := math.Max(math.Abs(l1-l2)+margin, math.Min(l1+l2-margin, r))
+	if r == 0 { //every direction is as close, so straight up
+		return Point{0, clamped}
+	} //if
+	return scalePoint(p, clamped/r)
+} //end nearestReachable
+
+//Calculate every pair of joint angles that reaches a point
+//Point p - endpoint in Cartesian space
+//float64 l1 - length of first joint
+//float64 l2 - length of second joint
+//return - the solutions, none if the point is out of reach and one if the arm is straight or folded
+func InverseKinematicsAll(p Point, l1, l2 float64) []ikSolution {
+	r2 := p.x*p.x + p.y*p.y
+	c2 := (r2 - l1*l1 - l2*l2) / (2 * l1 * l2) //cosine of the second joint angle
+	if c2 < -1-1e-9 || c2 > 1+1e-9 {
+		return nil
+	} //if
+	c2 = math.Max(-1, math.Min(1, c2))
+
+	theta := math.Atan2(p.y, p.x) //angle counterclockwise from x-axis to point
+	solve := func(q2 float64) ikSolution {
+		return ikSolution{theta - math.Atan2(l2*math.Sin(q2), l1+l2*math.Cos(q2)), q2}
+	} //end solve
+
+	q2 := math.Acos(c2)
+	if q2 == 0 || q2 == math.Pi { //both solutions are the same
+		return []ikSolution{solve(q2)}
+	} //if
+	return []ikSolution{solve(q2), solve(-q2)}
+} //end InverseKinematicsAll
+
+//InverseKinematics calculates the joint angles given an endpoint, closest to the current angles
+//Point p - endpoint in Cartesian space
+//float64 ang1 - current angle of first joint
+//float64 ang2 - current angle of second joint
+//float64 a1 - length of first joint
+//float64 a2 - length of second joint
+//return - new first and second joint angles, and an *ikError if the point can't be reached
+func InverseKinematics(p Point, ang1, ang2, a1, a2 float64) (float64, float64, error) {
+	arm := Arm2{arm1: &Arm{length: a1, angle: ang1}, arm2: &Arm{length: a2, angle: ang2}}
+	s, err := arm.solveIK(p, closestIK)
+	return s.q1, s.q2, err
+} //end InverseKinematics
+
+//Copy the arm with its first two joints at some angles, leaving the arm itself where it is
+//float64 q1 - angle of the first joint
+//float64 q2 - angle of the second joint relative to the first
+//return - the copy, sharing only the wrist
+func (a2 Arm2) withAngles(q1, q2 float64) Arm2 {
+	j1, j2 := *a2.arm1, *a2.arm2
+	j1.angle, j2.angle = q1, q2
+	a2.arm1, a2.arm2 = &j1, &j2
+	return a2
+} //end withAngles
+
+//Shift an angle by whole turns to be as close as possible to another
+//float64 angle - angle to shift in radians
+//float64 ref - angle to be close to in radians
+//return - the shifted angle
+func nearestTurn(angle, ref float64) float64 {
+	return angle + 2*math.Pi*math.Round((ref-angle)/(2*math.Pi))
+} //end nearestTurn
+
+//Pick the solution with the smallest joint space distance to some angles
+//[]ikSolution solutions - solutions to pick from, not empty
+//float64 q1 - angle of the first joint
+//float64 q2 - angle of the second joint
+//return - the closest solution, shifted by whole turns towards the angles
+func closestSolution(solutions []ikSolution, q1, q2 float64) ikSolution {
+	best, bestDist := ikSolution{}, math.Inf(1)
+	for _, s := range solutions {
+		s = ikSolution{nearestTurn(s.q1, q1), nearestTurn(s.q2, q2)}
+		if d := math.Hypot(s.q1-q1, s.q2-q2); d < bestDist {
+			best, bestDist = s, d
+		} //if
+	} //loop
+	return best
+} //end closestSolution
+
+//Pick a solution for a point with a policy
+//Point p - point to reach
+//ikPolicy policy - how to pick between the solutions
+//return - the solution, and an *ikError if the point can't be reached or is singular,
+//in which case the solution reaches the nearest point that can be
+func (a2 Arm2) solveIK(p Point, policy ikPolicy) (ikSolution, error) {
+	q1, q2 := a2.arm1.angle, a2.arm2.angle
+	l1, l2 := a2.arm1.length, a2.arm2.length
+
+	//out of reach, solve for the closest point in reach instead
+	reach := checkReach(p, l1, l2)
+	if reach == tooFar || reach == tooClose {
+		nearest := nearestReachable(p, l1, l2)
+		fallback := ikSolution{q1, q2} //an arm with no length can't reach anywhere, so it stays put
+		if r := checkReach(nearest, l1, l2); r != tooFar && r != tooClose {
+			fallback, _ = a2.solveIK(nearest, policy)
+		} //if
+		return fallback, &ikError{reach: reach, goal: p, nearest: nearest, fallback: fallback}
+	} //if
+
+	//the same angles as the current ones where possible, so no joint spins a whole turn
+	var valid []ikSolution
+	solutions := InverseKinematicsAll(p, l1, l2)
+	for i, s := range solutions {
+		solutions[i] = ikSolution{nearestTurn(s.q1, q1), nearestTurn(s.q2, q2)}
+		if a2.arm1.withinLimits(solutions[i].q1) && a2.arm2.withinLimits(solutions[i].q2) {
+			valid = append(valid, solutions[i])
+		} //if
+	} //loop
+	if len(valid) == 0 { //as close as the limits allow to the closest solution, nothing stops the joints past them
+		closest := closestSolution(solutions, q1, q2)
+		fallback := ikSolution{a2.arm1.clampToLimits(closest.q1), a2.arm2.clampToLimits(closest.q2)}
+		nearest := a2.calcEndPoint(fallback.q1, fallback.q2)
+		return fallback, &ikError{reach: outsideLimits, goal: p, nearest: nearest, fallback: fallback}
+	} //if
+	solutions = valid
+
+	//only pick between the solutions that miss the obstacles, like the workspace
+	var clear []ikSolution
+	for _, s := range solutions {
+		if !a2.collides(s.q1, s.q2, a2.obstacles) {
+			clear = append(clear, s)
+		} //if
+	} //loop
+	if len(clear) > 0 {
+		solutions = clear
+	} //if
+
+	best := closestSolution(solutions, q1, q2)
+	switch policy {
+	case elbowUpIK, elbowDownIK:
+		best = solutions[0]
+		for _, s := range solutions[1:] {
+			higher := math.Sin(s.q1) > math.Sin(best.q1) //height of the elbow
+			if higher == (policy == elbowUpIK) {
+				best = s
+			} //if
+		} //loop
+
+	case minTravelIK:
+		bestTime := math.Inf(1)
+		for _, s := range solutions {
+			moveTime := math.Max(a2.arm1.calcMoveTime(s.q1-q1), a2.arm2.calcMoveTime(s.q2-q2))
+			if moveTime < bestTime {
+				best, bestTime = s, moveTime
+			} //if
+		} //loop
+
+	} //switch
+
+	if reach == singular { //reachable, but only just
+		return best, &ikError{reach: singular, goal: p, nearest: p, fallback: best}
+	} //if
+	return best, nil
+} //end solveIK
@@ -0,0 +1,252 @@
+//iksolver
+//Created on: 10/19/2026
+//Inverse kinematics solvers behind one interface, the closed form for two links and an iterative one for any chain
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+//ikResult is the joint angles a solver found and how it got there
+type ikResult struct {
+	angles     []float64 //angle of each joint relative to the link before it in radians
+	iterations int       //steps taken, zero for a closed form solution
+	residual   float64   //distance left to the target in meters, with the heading weighted in
+	converged  bool      //whether the residual is within the solver's tolerance
+} //end struct
+
+//ikSolver finds joint angles that put the end of a chain on a target
+type ikSolver interface {
+	//Solve for a target starting from some joint angles
+	//Goal target - where the end of the chain should go
+	//[]float64 start - current angle of each joint in radians
+	//return - the solution, and an error if it doesn't reach the target
+	solve(target Goal, start []float64) (ikResult, error)
+} //end interface
+
+//two links can only put their end point somewhere, the heading comes with it
+var errHeadingTwoLinks = errors.New("two links can't set the heading of the end point")
+
+//analyticSolver is the closed form solution for the two-jointed arm and its wrist
+type analyticSolver struct {
+	arm2     Arm2           //arm to solve for, with its joint limits
+	policy   ikPolicy       //how to pick between the solutions
+	fallback orientFallback //what to give up when the heading can't be met, with a wrist
+} //end struct
+
+//Solve for a target with the closed form solution
+//Goal target - where the end of the arm should go, with a heading only if there is a wrist
+//[]float64 start - current angles of the joints in radians
+//return - the solution, and an *ikError if the point can't be reached
+func (s analyticSolver) solve(target Goal, start []float64) (ikResult, error) {
+	//solve from the starting angles without moving the arm itself
+	a2 := s.arm2.withAngles(start[0], start[1])
+	if a2.arm3 != nil {
+		wrist := *a2.arm3
+		wrist.angle = start[2]
+		a2.arm3 = &wrist
+
+		sol, q3, err := a2.solveWristIK(target, s.policy, s.fallback)
+		tip := a2.calcClawPoint(sol.q1, sol.q2, q3)
+		residual := math.Hypot(tip.x-target.point.x, tip.y-target.point.y)
+		return ikResult{angles: []float64{sol.q1, sol.q2, q3}, residual: residual, converged: err == nil}, err
+	} //if
+
+	sol, err := a2.solveIK(target.point, s.policy)
+	if err == nil && target.oriented { //the point is all two links can meet
+		err = errHeadingTwoLinks
+	} //if
+	tip := a2.calcEndPoint(sol.q1, sol.q2)
+	residual := math.Hypot(tip.x-target.point.x, tip.y-target.point.y)
+	return ikResult{angles: []float64{sol.q1, sol.q2}, residual: residual, converged: err == nil}, err
+} //end solve
+
+//numericSolver solves any planar chain with Levenberg-Marquardt damped least squares steps
+type numericSolver struct {
+	lengths       []float64 //length of each link in meters
+	mins          []float64 //lowest angle of each joint in radians, -Inf without limits
+	maxs          []float64 //highest angle of each joint in radians, Inf without limits
+	maxIterations int       //most steps to take before giving up
+	tolerance     float64   //residual that counts as reaching the target in meters
+	damping       float64   //starting damping of each step in meters
+	headingWeight float64   //meters of residual per radian of heading error
+} //end struct
+
+//Create a numeric solver for a chain without joint limits
+//[]float64 lengths - length of each link in meters
+func newNumericSolver(lengths []float64) *numericSolver {
+	s := &numericSolver{lengths: lengths, maxIterations: 100, tolerance: 1e-6, damping: 0.01}
+	reach := 0.0
+	for _, l := range lengths {
+		reach += l
+		s.mins = append(s.mins, math.Inf(-1))
+		s.maxs = append(s.maxs, math.Inf(1))
+	} //loop
+	s.headingWeight = reach / (2 * math.Pi) //a whole turn counts as much as the whole reach
+	return s
+} //end newNumericSolver
+
+//Limit the range of a joint
+//int i - index of the joint
+//float64 min - lowest angle in radians
+//float64 max - highest angle in radians
+func (s *numericSolver) setLimits(i int, min, max float64) {
+	s.mins[i], s.maxs[i] = min, max
+} //end setLimits
+
+//Create a numeric solver for the arm and its wrist if it has one, with their joint limits
+func (a2 Arm2) numericSolver() *numericSolver {
+	joints := []*Arm{a2.arm1, a2.arm2}
+	if a2.arm3 != nil {
+		joints = append(joints, a2.arm3)
+	} //if
+
+	var lengths []float64
+	for _, a := range joints {
+		lengths = append(lengths, a.length)
+	} //loop
+	s := newNumericSolver(lengths)
+	for i, a := range joints {
+		if a.limited {
+			s.setLimits(i, a.minAngle, a.maxAngle)
+		} //if
+	} //loop
+	return s
+} //end numericSolver
+
+//Keep each joint angle within its limits
+//[]float64 q - joint angles in radians, changed in place
+func (s numericSolver) clampAngles(q []float64) {
+	for i := range q {
+		q[i] = math.Max(s.mins[i], math.Min(s.maxs[i], q[i]))
+	} //loop
+} //end clampAngles
+
+//Calculate how far the chain is from a target
+//[]float64 q - joint angles in radians
+//Goal target - where the end of the chain should go
+//return - the weighted error to the target, x and y then heading if oriented, and its length
+func (s numericSolver) calcError(q []float64, target Goal) (matrix, float64) {
+	c := planarChain{s.lengths, q}
+	tip := c.endPoint()
+	if !target.oriented {
+		e := newMatrix(2, 1, target.point.x-tip.x, target.point.y-tip.y)
+		return e, math.Hypot(e.at(0, 0), e.at(1, 0))
+	} //if
+
+	heading := 0.0
+	for _, a := range q {
+		heading += a
+	} //loop
+	dh := math.Remainder(target.heading-heading, 2*math.Pi) * s.headingWeight //the shorter way around
+	e := newMatrix(3, 1, target.point.x-tip.x, target.point.y-tip.y, dh)
+	return e, math.Sqrt(e.at(0, 0)*e.at(0, 0) + e.at(1, 0)*e.at(1, 0) + dh*dh)
+} //end calcError
+
+//Calculate a damped least squares step towards a target, leaving out joints pinned at their limits
+//[]float64 q - joint angles in radians
+//Goal target - where the end of the chain should go
+//matrix e - weighted error to the target
+//float64 damping - damping of the step in meters
+//return - the change in each joint angle in radians
+func (s numericSolver) calcStep(q []float64, target Goal, e matrix, damping float64) []float64 {
+	c := planarChain{s.lengths, q}
+	J := c.positionJacobian()
+	if target.oriented {
+		J = c.jacobian()
+		for i := 0; i < J.cols; i++ {
+			J.set(2, i, s.headingWeight)
+		} //loop
+	} //if
+
+	dq := make([]float64, len(q))
+	for pass := 0; pass < 2; pass++ {
+		//dq = J'(JJ' + damping^2 I)^-1 e
+		A := J.mul(J.transpose()).add(identityMatrix(J.rows).scale(damping * damping))
+		inv, err := A.inverse()
+		if err != nil {
+			return dq
+		} //if
+		step := J.transpose().mul(inv).mul(e)
+
+		//a joint at its limit that would go past it can't help, so solve again without it
+		pinned := false
+		for i := range dq {
+			dq[i] = step.at(i, 0)
+			if (q[i] <= s.mins[i] && dq[i] < 0) || (q[i] >= s.maxs[i] && dq[i] > 0) {
+				for r := 0; r < J.rows; r++ {
+					J.set(r, i, 0)
+				} //loop
+				pinned = true
+			} //if
+		} //loop
+		if !pinned {
+			break
+		} //if
+	} //loop
+	return dq
+} //end calcStep
+
+//Solve for a target iteratively, starting from some joint angles
+//Goal target - where the end of the chain should go, its heading met only with three links or more
+//[]float64 start - current angle of each joint in radians
+//return - the closest solution found, and a *convergenceError if it doesn't reach the target
+func (s numericSolver) solve(target Goal, start []float64) (ikResult, error) {
+	if target.oriented && len(s.lengths) < 3 { //the point is all two links can meet
+		target.oriented = false
+		res, err := s.solve(target, start)
+		if err == nil {
+			err = errHeadingTwoLinks
+		} //if
+		return res, err
+	} //if
+
+	q := append([]float64{}, start...)
+	s.clampAngles(q)
+	e, residual := s.calcError(q, target)
+	damping := s.damping
+
+	res := ikResult{}
+	for res.iterations < s.maxIterations && residual > s.tolerance {
+		res.iterations++
+		dq := s.calcStep(q, target, e, damping)
+
+		trial := make([]float64, len(q))
+		for i := range q {
+			trial[i] = q[i] + dq[i]
+		} //loop
+		s.clampAngles(trial)
+
+		//take steps that get closer and trust the linearization more, otherwise damp it more and try again
+		if te, tr := s.calcError(trial, target); tr < residual {
+			q, e, residual = trial, te, tr
+			damping = math.Max(damping/2, 1e-9)
+		} else {
+			damping *= 4
+			if damping > 1e3 { //no step gets closer, stuck at the closest the chain can get
+				break
+			} //if
+		} //if
+	} //loop
+
+	res.angles, res.residual, res.converged = q, residual, residual <= s.tolerance
+	if !res.converged {
+		return res, &convergenceError{target: target, result: res}
+	} //if
+	return res, nil
+} //end solve
+
+//convergenceError is a numeric solve that stopped short of its target
+type convergenceError struct {
+	target Goal     //target that was asked for
+	result ikResult //closest the solver got
+} //end struct
+
+//get a string representation of the error
+func (e *convergenceError) Error() string {
+	return fmt.Sprintf("did not converge to (%.3f, %.3f) after %d iterations, %.4f m away",
+		e.target.point.x, e.target.point.y, e.result.iterations, e.result.residual)
+} //end Error
@@ -0,0 +1,142 @@
+//impedance
+//Created on: 10/19/2026
+//Impedance control that makes the end of the arm act like a spring-damper, and the forces it can push against
+
+package main
+
+import (
+	"math"
+)
+
+//default stiffness in N/m and damping in Ns/m of the end point along x and y
+var impedanceDefaultK = []float64{400, 400}
+var impedanceDefaultD = []float64{150, 150}
+
+func init() {
+	RegisterController("impedance", func(a2 Arm2) Controller {
+		return newImpedance(a2, ctrlSettings.impK, ctrlSettings.impD)
+	})
+} //end init
+
+//impedancecontroller pulls the end point towards a target like a spring-damper
+type impedancecontroller struct {
+	arm2      Arm2      //arm to control
+	stiffness []float64 //spring constants along x and y in N/m
+	damping   []float64 //damping along x and y in Ns/m
+	target    Point     //point the spring pulls towards in meters
+} //end struct
+
+//Create an impedance controller
+//Arm2 a2 - arm to control
+//[]float64 stiffness - spring constants along x and y in N/m
+//[]float64 damping - damping along x and y in Ns/m
+func newImpedance(a2 Arm2, stiffness, damping []float64) *impedancecontroller {
+	return &impedancecontroller{arm2: a2, stiffness: stiffness, damping: damping}
+} //end newImpedance
+
+//Calculate the voltages for both joints
+//ArmState state - measured state of the arm
+//ArmState goal - state to drive the arm to
+//float64 t - simulation time in seconds
+//return - voltages for the first and second joints
+func (c *impedancecontroller) calculate(state, goal ArmState, t float64) (float64, float64) {
+	tau1, tau2 := c.calcImpedanceTorques(state)
+	return c.arm2.arm1.torqueToVoltageAt(tau1, state[1]), c.arm2.arm2.torqueToVoltageAt(tau2, state[3])
+} //end calculate
+
+//Move the spring to the end point of the goal
+//ArmState state - measured state of the arm
+//ArmState goal - state to drive the arm to
+//float64 t - simulation time in seconds
+func (c *impedancecontroller) reset(state, goal ArmState, t float64) {
+	c.target = c.arm2.calcEndPoint(goal[0], goal[2])
+} //end reset
+
+//Calculate the joint torques that make the end point act like a spring-damper
+//ArmState state - state of the arm
+//return - torques at the first and second joints in Nm, including holding up the arm
+func (c impedancecontroller) calcImpedanceTorques(state ArmState) (float64, float64) {
+	tip := c.arm2.calcEndPoint(state[0], state[2])
+	tipVel := c.arm2.calcTipVelocity(state)
+
+	//F = K(x_d - x) - D xdot
+	force := Point{c.stiffness[0]*(c.target.x-tip.x) - c.damping[0]*tipVel.x,
+		c.stiffness[1]*(c.target.y-tip.y) - c.damping[1]*tipVel.y}
+
+	//tau = J'F, on top of holding up the arm
+	tau1, tau2 := c.arm2.forceToTorques(state[0], state[2], force)
+	grav1, grav2 := c.arm2.calcGravTorques(state[0], state[2])
+	return tau1 + grav1, tau2 + grav2
+} //end calcImpedanceTorques
+
+//Calculate the velocity of the end point
+//ArmState x - state of the arm
+//return - end point velocity in meters/second
+func (a2 Arm2) calcTipVelocity(x ArmState) Point {
+	J := a2.calcJacobian(x[0], x[2])
+	v := J.mul(newMatrix(2, 1, x[1], x[3]))
+	return Point{v.at(0, 0), v.at(1, 0)}
+} //end calcTipVelocity
+
+//Calculate the joint torques that are the same as a force at the end point
+//float64 q1 - angle of the first joint
+//float64 q2 - angle of the second joint relative to the first
+//Point force - force at the end point in N
+//return - torques at the first and second joints in Nm
+func (a2 Arm2) forceToTorques(q1, q2 float64, force Point) (float64, float64) {
+	tau := a2.calcJacobian(q1, q2).transpose().mul(newMatrix(2, 1, force.x, force.y))
+	return tau.at(0, 0), tau.at(1, 0)
+} //end forceToTorques
+
+//Calculate the total force pushed on the end point from outside the arm
+//return - force at the end point in N
+func (a2 Arm2) calcExternalForce() Point {
+	force := a2.extForce
+	if a2.surface != nil {
+		x := a2.getState()
+		contact := a2.surface.calcForce(a2.calcEndPoint(x[0], x[2]), a2.calcTipVelocity(x))
+		force = Point{force.x + contact.x, force.y + contact.y}
+	} //if
+	return force
+} //end calcExternalForce
+
+//contactSurface is a flat, springy surface the end point can push against
+type contactSurface struct {
+	point     Point   //any point on the surface in meters
+	normal    Point   //unit direction out of the surface, towards free space
+	stiffness float64 //how hard the surface pushes back per meter it is pressed in, in N/m
+	damping   float64 //resistance to pressing in per meter/second, in Ns/m
+} //end struct
+
+//Create a contact surface
+//Point point - any point on the surface in meters
+//Point normal - direction out of the surface, does not need to be a unit vector
+//float64 stiffness - push back per meter pressed in, in N/m
+//float64 damping - resistance per meter/second pressed in, in Ns/m
+func newContactSurface(point, normal Point, stiffness, damping float64) *contactSurface {
+	length := math.Hypot(normal.x, normal.y)
+	return &contactSurface{point, scalePoint(normal, 1/length), stiffness, damping}
+} //end newContactSurface
+
+//Calculate how far a point is pressed into the surface
+//Point p - point to check
+//return - depth in meters, negative when the point is in free space
+func (s contactSurface) calcDepth(p Point) float64 {
+	return -((p.x-s.point.x)*s.normal.x + (p.y-s.point.y)*s.normal.y)
+} //end calcDepth
+
+//Calculate the force the surface pushes on a point with
+//Point p - point touching the surface
+//Point v - velocity of the point
+//return - force on the point in N, zero when not touching
+func (s contactSurface) calcForce(p, v Point) Point {
+	depth := s.calcDepth(p)
+	if depth <= 0 { //not touching
+		return Point{0, 0}
+	} //if
+
+	//a surface can only push, never pull
+	inVel := -(v.x*s.normal.x + v.y*s.normal.y)
+	push := math.Max(0, s.stiffness*depth+s.damping*inVel)
+	return scalePoint(s.normal, push)
+} //end calcForce
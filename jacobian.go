@@ -0,0 +1,109 @@
+//jacobian
+//Created on: 10/19/2026
+//Jacobian of the two-jointed arm and resolved-rate control of its end point
+
+package main
+
+import (
+	"math"
+)
+
+//Calculate the end point of the arm from its joint angles
+//float64 q1 - angle of the first joint
+//float64 q2 - angle of the second joint relative to the first
+//return - end point in meters relative to the base of the arm
+func (a2 Arm2) calcEndPoint(q1, q2 float64) Point {
+	return a2.chainAt(q1, q2).endPoint()
+} //end calcEndPoint
+
+//Calculate the Jacobian of the end point with respect to the joint angles
+//float64 q1 - angle of the first joint
+//float64 q2 - angle of the second joint relative to the first
+//return - 2x2 matrix mapping joint velocities to end point velocity
+func (a2 Arm2) calcJacobian(q1, q2 float64) matrix {
+	return a2.chainAt(q1, q2).positionJacobian()
+} //end calcJacobian
+
+//Calculate the joint velocities that move the end point at a velocity with damped least squares
+//float64 q1 - angle of the first joint
+//float64 q2 - angle of the second joint relative to the first
+//Point v - end point velocity in meters/second
+//float64 damping - largest damping, used at a singularity
+//return - velocities of the first and second joints in radians/second
+func (a2 Arm2) calcJointRates(q1, q2 float64, v Point, damping float64) (float64, float64) {
+	J := a2.calcJacobian(q1, q2)
+	Jt := J.transpose()
+
+	//damp more the closer the arm gets to a singularity, not at all away from one
+	w := math.Abs(J.at(0, 0)*J.at(1, 1) - J.at(0, 1)*J.at(1, 0)) //manipulability
+	w0 := 0.1 * a2.arm1.length * a2.arm2.length                  //manipulability where damping starts
+	lambda2 := 0.0
+	if w < w0 {
+		lambda2 = damping * damping * (1 - (w/w0)*(w/w0))
+	} //if
+
+	//qdot = J'(JJ' + lambda^2 I)^-1 v
+	inv, err := J.mul(Jt).add(identityMatrix(2).scale(lambda2)).inverse()
+	if err != nil { //exactly singular with no damping
+		return 0, 0
+	} //if
+	qd := Jt.mul(inv).mul(newMatrix(2, 1, v.x, v.y))
+
+	return qd.at(0, 0), qd.at(1, 0)
+} //end calcJointRates
+
+//rateSource gives the desired velocity of the end point
+//Point tip - current end point in meters
+//float64 t - simulation time in seconds
+//return - desired end point velocity in meters/second
+type rateSource func(tip Point, t float64) Point
+
+//Create a source that drives the end point towards a target, such as the mouse
+//*Point target - point to move towards, nil to stay still
+//float64 gain - velocity per meter of distance in 1/seconds
+//float64 maxSpeed - fastest the end point can move in meters/second
+func targetRateSource(target *Point, gain, maxSpeed float64) rateSource {
+	return func(tip Point, t float64) Point {
+		if target == nil {
+			return Point{0, 0}
+		} //if
+		v := scalePoint(Point{target.x - tip.x, target.y - tip.y}, gain)
+		if speed := math.Hypot(v.x, v.y); speed > maxSpeed {
+			v = scalePoint(v, maxSpeed/speed)
+		} //if
+		return v
+	} //end func
+} //end targetRateSource
+
+//Create a source that moves the end point at a constant velocity, such as one typed in
+//Point v - end point velocity in meters/second
+func constantRateSource(v Point) rateSource {
+	return func(tip Point, t float64) Point {
+		return v
+	} //end func
+} //end constantRateSource
+
+//Create a straight line path through time that stays at its end once it gets there
+//Point from - start of the line in meters
+//Point to - end of the line in meters
+//float64 start - time the path starts at in seconds
+//float64 duration - time to go along the line in seconds
+//return - end point at a time
+func linePath(from, to Point, start, duration float64) func(t float64) Point {
+	return func(t float64) Point {
+		s := math.Max(0, math.Min(1, (t-start)/duration)) //fraction of the way along
+		return Point{from.x + s*(to.x-from.x), from.y + s*(to.y-from.y)}
+	} //end func
+} //end linePath
+
+//Create a source that follows a path through time
+//func path - end point at a time
+//float64 gain - correction velocity per meter of error in 1/seconds
+func pathRateSource(path func(t float64) Point, gain float64) rateSource {
+	return func(tip Point, t float64) Point {
+		const h = 1e-3 //finite difference step in seconds
+		p, next := path(t), path(t+h)
+		ff := Point{(next.x - p.x) / h, (next.y - p.y) / h} //velocity of the path
+		return Point{ff.x + gain*(p.x-tip.x), ff.y + gain*(p.y-tip.y)}
+	} //end func
+} //end pathRateSource
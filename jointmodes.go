@@ -0,0 +1,76 @@
+//jointmodes
+//Created on: 10/19/2026
+//Closed-loop velocity and current control of a joint, like a smart motor controller
+
+package main
+
+import "fmt"
+
+//JointMode is how a joint is being commanded
+type JointMode int
+
+const (
+	percentOutput JointMode = iota //fraction of the max voltage, open loop
+	positionMode                   //angle in radians with PID and gravity feedforward
+	velocityMode                   //angular velocity in radians/second with PID and velocity feedforward
+	currentMode                    //current per motor in Amps with PID and back-EMF feedforward
+)
+
+//get a string representation of the mode
+func (m JointMode) String() string {
+	return [...]string{"percentOutput", "position", "velocity", "current"}[m]
+} //end String
+
+//Find a joint mode by name
+//string name - name of the mode
+//return - the mode, or an error if there is none with the name
+func parseJointMode(name string) (JointMode, error) {
+	for m := percentOutput; m <= currentMode; m++ {
+		if m.String() == name {
+			return m, nil
+		} //if
+	} //loop
+	return percentOutput, fmt.Errorf("no joint mode named %q, use percentOutput, position, velocity or current", name)
+} //end parseJointMode
+
+//jointCommand is a setpoint for a joint in one of its modes
+type jointCommand struct {
+	mode  JointMode //how the joint is commanded
+	value float64   //setpoint in the units of the mode
+} //end struct
+
+//drive the arm at a velocity using PID with a velocity and gravity feedforward
+//float64 setpoint - goal velocity in radians/second
+//float64 gravTorque - torque needed to hold up the arm and everything on it in Nm
+func (a *Arm) moveVelocity(setpoint, gravTorque float64) {
+	pid := a.velPID.calcPID(setpoint, a.vel, a.maxVel*0.01)
+	a.voltage = MaxVoltage*OutputClamp(pid, -1, 1) + a.torqueToVoltageAt(gravTorque, setpoint)
+	a.update() //update the arm
+} //end moveVelocity
+
+//drive the arm with a current using PID with a back-EMF feedforward
+//float64 setpoint - goal current through each motor in Amps
+func (a *Arm) moveCurrent(setpoint float64) {
+	pid := a.curPID.calcPID(setpoint, a.current, 0.5) //current from the last voltage applied
+
+	//the motors need the voltage across their resistance plus what they generate spinning
+	ff := setpoint*a.motor.kResistance + a.calcBackEMF(a.vel)
+	a.voltage = MaxVoltage*OutputClamp(pid, -1, 1) + ff
+	a.update() //update the arm
+} //end moveCurrent
+
+//drive the arm with a command in any mode
+//jointCommand cmd - mode and setpoint to drive the arm with
+//float64 gravTorque - torque needed to hold up the arm and everything on it in Nm
+func (a *Arm) command(cmd jointCommand, gravTorque float64) {
+	switch cmd.mode {
+	case percentOutput:
+		a.setOutput(cmd.value)
+	case positionMode:
+		a.movePIDWithFF(cmd.value, a.angle, ToRadians(1), a.torqueToVoltage(gravTorque))
+	case velocityMode:
+		a.moveVelocity(cmd.value, gravTorque)
+	case currentMode:
+		a.moveCurrent(cmd.value)
+	} //switch
+} //end command
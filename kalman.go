@@ -0,0 +1,136 @@
+//kalman
+//Created on: 10/19/2026
+//Kalman filter that estimates the joint angles and velocities from encoder angles and applied voltage
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+//kalmanfilter estimates the state of a two-jointed arm using the arm's model as the process model
+type kalmanfilter struct {
+	//configured attributes
+	Q        matrix //process noise covariance
+	R        matrix //measurement noise covariance
+	extended bool   //whether to relinearize the model every step (EKF) or keep one linearization
+
+	//calculated attributes
+	x ArmState //estimated state
+	P matrix   //covariance of the estimate
+
+	x0     ArmState   //state of the fixed linearization
+	u0     [2]float64 //voltages of the fixed linearization
+	x0Next ArmState   //state one step after the linearization state
+	A      matrix     //fixed state matrix
+	B      matrix     //fixed input matrix
+} //end struct
+
+//measurement matrix, the encoders measure the two joint angles
+var kalmanH = newMatrix(2, 4,
+	1, 0, 0, 0,
+	0, 0, 1, 0)
+
+//Create a Kalman filter for an arm
+//Arm2 a2 - arm to estimate the state of
+//ArmState x - initial state estimate
+//[]float64 processNoise - standard deviations of the process noise for each state
+//[]float64 measNoise - standard deviations of the encoder noise for each joint
+//bool extended - whether to relinearize the model every step
+func newKalmanFilter(a2 Arm2, x ArmState, processNoise, measNoise []float64, extended bool) *kalmanfilter {
+	kf := new(kalmanfilter)
+	kf.Q = diagonalMatrix(squares(processNoise)...)
+	kf.R = diagonalMatrix(squares(measNoise)...)
+	kf.extended = extended
+
+	kf.x = x
+	kf.P = kf.Q.scale(10) //start unsure of the initial state
+	kf.relinearize(a2, x)
+
+	return kf
+} //end newKalmanFilter
+
+//Linearize the model about a state with the voltage that holds it there
+//Arm2 a2 - arm being estimated
+//ArmState x - state to linearize about
+func (kf *kalmanfilter) relinearize(a2 Arm2, x ArmState) {
+	tau1, tau2 := a2.calcGravTorques(x[0], x[2])
+	kf.x0 = ArmState{x[0], 0, x[2], 0}
+	kf.u0 = [2]float64{a2.arm1.torqueToVoltage(tau1), a2.arm2.torqueToVoltage(tau2)}
+	kf.x0Next = a2.predict(kf.x0, kf.u0[0], kf.u0[1])
+	kf.A, kf.B = a2.linearize(kf.x0, kf.u0[0], kf.u0[1])
+} //end relinearize
+
+//Predict the state one time step later from the voltages applied
+//Arm2 a2 - arm being estimated
+//float64 v1 - voltage applied to the first joint
+//float64 v2 - voltage applied to the second joint
+func (kf *kalmanfilter) predict(a2 Arm2, v1, v2 float64) {
+	var F matrix
+	if kf.extended { //nonlinear model with its Jacobian at the estimate
+		F, _ = a2.linearize(kf.x, v1, v2)
+		kf.x = a2.predict(kf.x, v1, v2)
+	} else { //x' = f(x0,u0) + A(x - x0) + B(u - u0)
+		F = kf.A
+		dx := newMatrix(4, 1)
+		for i := range kf.x {
+			dx.set(i, 0, kf.x[i]-kf.x0[i])
+		} //loop
+		du := newMatrix(2, 1, v1-kf.u0[0], v2-kf.u0[1])
+		next := kf.A.mul(dx).add(kf.B.mul(du))
+		for i := range kf.x {
+			kf.x[i] = kf.x0Next[i] + next.at(i, 0)
+		} //loop
+	} //if
+
+	kf.P = F.mul(kf.P).mul(F.transpose()).add(kf.Q)
+} //end predict
+
+//Correct the estimate with the encoder measurements
+//float64 z1 - measured angle of the first joint
+//float64 z2 - measured angle of the second joint
+func (kf *kalmanfilter) correct(z1, z2 float64) {
+	Ht := kalmanH.transpose()
+	S := kalmanH.mul(kf.P).mul(Ht).add(kf.R)
+	Sinv, err := S.inverse()
+	if err != nil { //no information to correct with
+		return
+	} //if
+	K := kf.P.mul(Ht).mul(Sinv)
+
+	y := newMatrix(2, 1, z1-kf.x[0], z2-kf.x[2]) //innovation
+	dx := K.mul(y)
+	for i := range kf.x {
+		kf.x[i] += dx.at(i, 0)
+	} //loop
+
+	kf.P = identityMatrix(4).sub(K.mul(kalmanH)).mul(kf.P)
+} //end correct
+
+//Square every value in a list
+//[]float64 values - values to square
+func squares(values []float64) []float64 {
+	sq := make([]float64, len(values))
+	for i, v := range values {
+		sq[i] = v * v
+	} //loop
+	return sq
+} //end squares
+
+//Write the true and estimated state as a line of CSV
+//io.Writer w - where to write the line
+//float64 t - time in seconds
+//ArmState truth - true state of the arm
+//*ArmState est - estimated state of the arm, nil to leave the estimate columns empty
+func logEstimate(w io.Writer, t float64, truth ArmState, est *ArmState) {
+	fmt.Fprintf(w, "%.3f,%f,%f,%f,%f", t, truth[0], truth[1], truth[2], truth[3])
+	if est == nil { //nothing estimated
+		fmt.Fprintln(w, ",,,,")
+		return
+	} //if
+	fmt.Fprintf(w, ",%f,%f,%f,%f\n", est[0], est[1], est[2], est[3])
+} //end logEstimate
+
+//header for the CSV written by logEstimate
+const estimateHeader = "time,angle1,vel1,angle2,vel2,estAngle1,estVel1,estAngle2,estVel2"
@@ -0,0 +1,171 @@
+//lqr
+//Created on: 10/19/2026
+//A linear-quadratic regulator for the two-jointed arm derived from its linearized model
+
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+//default LQR weights from Bryson's rule, 1/(max error)^2 with 1 degree, 0.5 rad/s and 12 V
+var lqrDefaultQ = []float64{3283, 4, 3283, 4}
+var lqrDefaultR = []float64{0.0069, 0.0069}
+
+func init() {
+	RegisterController("lqr", func(a2 Arm2) Controller { return newLQRController(a2, ctrlSettings.lqrQ, ctrlSettings.lqrR) })
+} //end init
+
+//lqrcontroller drives both joints to a goal state with optimal state feedback
+type lqrcontroller struct {
+	//configured attributes
+	arm2 Arm2      //arm to control
+	q    []float64 //state weights for [angle1, vel1, angle2, vel2]
+	r    []float64 //input weights for [voltage1, voltage2]
+
+	//calculated attributes
+	goal ArmState //state the controller is linearized about
+	K    matrix   //2x4 feedback gain matrix
+} //end struct
+
+//Create an LQR controller for an arm about a goal state
+//Arm2 a2 - arm to control
+//ArmState goal - state to drive the arm to
+//[]float64 q - state weights, one per state
+//[]float64 r - input weights, one per joint
+//return - the controller, or an error if the Riccati equation has no solution
+func newLQR(a2 Arm2, goal ArmState, q, r []float64) (*lqrcontroller, error) {
+	if len(q) != 4 || len(r) != 2 {
+		return nil, errors.New("lqr needs 4 state weights and 2 input weights")
+	} //if
+
+	//linearize about the goal with the voltage that holds it there
+	tau1, tau2 := a2.calcGravTorques(goal[0], goal[2])
+	A, B := a2.linearize(goal, a2.arm1.torqueToVoltage(tau1), a2.arm2.torqueToVoltage(tau2))
+	Q := diagonalMatrix(q...)
+	R := diagonalMatrix(r...)
+
+	P, err := solveDARE(A, B, Q, R)
+	if err != nil {
+		return nil, err
+	} //if
+
+	//K = (R + B'PB)^-1 B'PA
+	Bt := B.transpose()
+	inv, err := R.add(Bt.mul(P).mul(B)).inverse()
+	if err != nil {
+		return nil, err
+	} //if
+
+	return &lqrcontroller{arm2: a2, q: q, r: r, goal: goal, K: inv.mul(Bt).mul(P).mul(A)}, nil
+} //end newLQR
+
+//Create an LQR controller that is linearized about each new goal it is given
+//Arm2 a2 - arm to control
+//[]float64 q - state weights, one per state
+//[]float64 r - input weights, one per joint
+func newLQRController(a2 Arm2, q, r []float64) *lqrcontroller {
+	return &lqrcontroller{arm2: a2, q: q, r: r}
+} //end newLQRController
+
+//Calculate the voltages for both joints
+//ArmState state - measured state of the arm
+//ArmState goal - state to drive the arm to
+//float64 t - simulation time in seconds
+//return - voltages for the first and second joints
+func (lqr *lqrcontroller) calculate(state, goal ArmState, t float64) (float64, float64) {
+	if lqr.K.data == nil { //no gains for this goal, only hold the arm up
+		return calcFFArm2(lqr.arm2, 0, 0, 0, 0)
+	} //if
+	return lqr.calcLQR(lqr.arm2, state)
+} //end calculate
+
+//Recalculate the gains about a new goal
+//ArmState state - measured state of the arm
+//ArmState goal - state to drive the arm to
+//float64 t - simulation time in seconds
+func (lqr *lqrcontroller) reset(state, goal ArmState, t float64) {
+	next, err := newLQR(lqr.arm2, goal, lqr.q, lqr.r)
+	if err != nil {
+		fmt.Println("lqr:", err)
+		lqr.K = matrix{}
+		return
+	} //if
+	lqr.goal, lqr.K = next.goal, next.K
+} //end reset
+
+//Calculate the voltages for both joints
+//Arm2 a2 - arm being controlled, used for the gravity feedforward
+//ArmState x - current state of the arm
+//return - voltages for the first and second joints
+func (lqr *lqrcontroller) calcLQR(a2 Arm2, x ArmState) (float64, float64) {
+	err := newMatrix(4, 1)
+	for i := range x {
+		err.set(i, 0, x[i]-lqr.goal[i])
+	} //loop
+	u := lqr.K.mul(err) //u = -K(x - goal)
+
+	ff1, ff2 := calcFFArm2(a2, 0, 0, 0, 0)
+	return ff1 - u.at(0, 0), ff2 - u.at(1, 0)
+} //end calcLQR
+
+//Linearize the arm's discrete model about a state and voltage with finite differences
+//ArmState x - state to linearize about
+//float64 v1 - voltage on the first joint
+//float64 v2 - voltage on the second joint
+//return - the 4x4 state matrix A and 4x2 input matrix B
+func (a2 Arm2) linearize(x ArmState, v1, v2 float64) (matrix, matrix) {
+	const h = 1e-5 //finite difference step
+	A := newMatrix(4, 4)
+	B := newMatrix(4, 2)
+
+	for j := 0; j < 4; j++ {
+		up, down := x, x
+		up[j] += h
+		down[j] -= h
+		xUp, xDown := a2.predict(up, v1, v2), a2.predict(down, v1, v2)
+		for i := 0; i < 4; i++ {
+			A.set(i, j, (xUp[i]-xDown[i])/(2*h))
+		} //loop
+	} //loop
+
+	inputs := [2][2]float64{{h, 0}, {0, h}}
+	for j, du := range inputs {
+		xUp := a2.predict(x, v1+du[0], v2+du[1])
+		xDown := a2.predict(x, v1-du[0], v2-du[1])
+		for i := 0; i < 4; i++ {
+			B.set(i, j, (xUp[i]-xDown[i])/(2*h))
+		} //loop
+	} //loop
+
+	return A, B
+} //end linearize
+
+//Solve the discrete algebraic Riccati equation by iterating it until it converges
+//matrix A - state matrix
+//matrix B - input matrix
+//matrix Q - state weights
+//matrix R - input weights
+//return - the solution P, or an error if it does not converge
+func solveDARE(A, B, Q, R matrix) (matrix, error) {
+	At, Bt := A.transpose(), B.transpose()
+	P := Q
+
+	for i := 0; i < 100000; i++ {
+		//P = Q + A'PA - A'PB(R + B'PB)^-1 B'PA
+		inv, err := R.add(Bt.mul(P).mul(B)).inverse()
+		if err != nil {
+			return matrix{}, err
+		} //if
+		AtPB := At.mul(P).mul(B)
+		next := Q.add(At.mul(P).mul(A)).sub(AtPB.mul(inv).mul(AtPB.transpose()))
+
+		if next.sub(P).maxAbs() <= 1e-9*next.maxAbs() { //converged
+			return next, nil
+		} //if
+		P = next
+	} //loop
+
+	return matrix{}, errors.New("riccati equation did not converge")
+} //end solveDARE
@@ -6,10 +6,15 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/h8gi/canvas"
 	"golang.org/x/image/colornames"
 	"image/color"
-	// "math"
+	"math"
+	"os"
+	"strings"
 	"time"
 )
 
@@ -35,13 +40,93 @@ var robotArm *Arm   //arm struct
 var robotArm2 Arm2  //2-jointed arm
 var armloop ArmLoop //state machine for the arm
 
-var pts []Point   //points to move to
-var canAdd bool   //whether a point can be added by clicking to the set or not
-var canTrack bool //whether the arm can track its goal or not
-var t *time.Timer //timer for the arm
+var pts []Goal     //goals to move to
+var commandPts int //number of goals queued when the joints or end point started being commanded
+
+var reach *workspace //region the arm can reach, nil when it is the whole annulus
+
+var external *externalController //controller in another process, nil for none
+
+var reorder *fixedEnds //which queued points keep their place when reordering, nil to keep the order they were added
+var cycleTime float64  //predicted time to visit the queued points in seconds, as of the last reorder
+var released int       //number of queued points the arm can move to while reordering, the rest wait to be run
+var reordering bool    //whether the queued points are being reordered off the draw loop
+var reorderAgain bool  //whether points were added while reordering, so they have to be ordered again
+var reorders = make(chan reorderResult, 1) //orders found off the draw loop, waiting to be shown
+
+var arrival arrivalCriteria //rules for arriving at each point
+var canAdd bool             //whether a point can be added by clicking to the set or not
+var canTrack bool           //whether the arm can track its goal or not
+var t *time.Timer           //timer for the arm
+
+//command line options
+var ctrlFlag = flag.String("ctrl", "pid", "registered controller while tracking a goal, such as pid, pid-id, lqr, mpc or impedance")
+var ffFlag = flag.String("ff", "gravity", "feedforward for the pid controller: gravity, or id for inverse dynamics along a trajectory (the same as -ctrl pid-id)")
+var lqrQFlag = flag.String("lqrq", "3283,4,3283,4", "LQR state weights for angle1, vel1, angle2, vel2")
+var lqrRFlag = flag.String("lqrr", "0.0069,0.0069", "LQR input weights for voltage1, voltage2")
+var mpcHorizonFlag = flag.Int("mpch", 25, "MPC horizon in time steps, at least 1")
+var mpcCurrentFlag = flag.Float64("mpccurrent", 0, "MPC current limit per motor in Amps, 0 for none")
+var mpcLimitsFlag = flag.Bool("mpclimits", false, "whether MPC keeps the joints within the limits set by -limits1 and -limits2")
+var extCmdFlag = flag.String("extcmd", "", "command that runs an external controller over its stdin and stdout")
+var extListenFlag = flag.String("extlisten", "", "local address to wait for an external controller on, such as 127.0.0.1:5800")
+var tuneFlag = flag.Int("tune", 0, "joint to tune headlessly, 1 or 2, instead of running the simulator")
+var tunerFlag = flag.String("tuner", "relay", "tuning method: relay (Ziegler-Nichols gains from a relay test) or nm")
+var bodeFlag = flag.Int("bode", 0, "joint to sweep headlessly for its frequency response, 1 or 2, instead of running the simulator")
+var bodeMethodFlag = flag.String("bodemethod", "stepped", "sweep method: stepped or chirp")
+var bodeAmpFlag = flag.Float64("bodeamp", 1, "size of the sine voltage swept into the joint in Volts")
+var bodeOutFlag = flag.String("bodeout", "bode.csv", "CSV file to write the frequency response to")
+var ikFlag = flag.String("ik", "closest", "how to pick the joint angles for a goal: closest, elbowup, elbowdown or mintravel, within the joint limits")
+var ikSolverFlag = flag.String("iksolver", "analytic", "how to solve for the joint angles of a goal: analytic or numeric")
+var wristFlag = flag.Float64("wrist", 0, "length of a claw on a wrist joint at the end of the arm in meters, 0 for no wrist")
+var approachFlag = flag.String("approach", "", "angle in degrees the claw approaches each goal at, such as 0 to keep it level, empty for any")
+var orientFallbackFlag = flag.String("orientfallback", "heading", "what to give up when the approach angle can't be met: heading to keep it or point to keep the point")
+var ikFallbackFlag = flag.Bool("ikfallback", false, "whether to move as close as possible to goals that can't be reached instead of stopping")
+var limits1Flag = flag.String("limits1", "", "lowest and highest angles of the first joint in degrees, such as -10,190")
+var limits2Flag = flag.String("limits2", "", "lowest and highest angles of the second joint in degrees, such as -170,170")
+var ellipseFlag = flag.Bool("ellipse", false, "whether to draw the manipulability ellipse at the end of the arm")
+var floorFlag = flag.Bool("floor", false, "whether the floor blocks the arm from going below its base")
+var obstaclesFlag = flag.String("obstacles", "", "round obstacles the arm can't pass through as x,y,radius in meters, separated by semicolons")
+var planFlag = flag.Bool("plan", false, "whether to plan paths around the obstacles, followed by the pid-id controller")
+var splineFlag = flag.String("spline", "", "fit a cubic or quintic spline through all the queued points instead of stopping at each, empty to stop")
+var splineSpaceFlag = flag.String("splinespace", "joint", "space to fit the spline in: joint or cartesian")
+var splineVelFlag = flag.Float64("splinevel", 1, "velocity at the points along the spline as a fraction of the average either side, 0 to stop at each")
+var toppFlag = flag.Bool("topp", false, "whether to time each move as fast as the motor limits allow, followed by the pid-id controller")
+var toppVoltsFlag = flag.Float64("toppvolts", 10, "most voltage a time-optimal move can use, leaving the rest for feedback")
+var toppCurrentFlag = flag.Float64("toppcurrent", 0, "most current through each motor in a time-optimal move in Amps, 0 for no limit")
+var reorderFlag = flag.Bool("reorder", false, "whether to reorder the queued points to visit them all in the least predicted time")
+var reorderFixFlag = flag.String("reorderfix", "none", "queued points that keep their place when reordering: none, first, last or both")
+var payloadFlag = flag.Float64("payload", 0, "mass held at the end of the arm in kg")
+var impKFlag = flag.String("impk", "400,400", "impedance stiffness along x and y in N/m")
+var impDFlag = flag.String("impd", "150,150", "impedance damping along x and y in Ns/m")
+var forceFlag = flag.String("force", "0,0", "constant force pushed on the end of the arm along x and y in N")
+var surfaceFlag = flag.String("surface", "", "surface the arm can press against as x,y,normalx,normaly,stiffness,damping")
+var arriveJointFlag = flag.Float64("arrivejoint", 1, "largest error of either joint to arrive at a goal in degrees, 0 to ignore")
+var arriveRadiusFlag = flag.Float64("arriveradius", 0, "largest distance of the end of the arm from a goal to arrive in meters, 0 to ignore")
+var arriveVelFlag = flag.Float64("arrivevel", 0.1, "fastest either joint can move to arrive at a goal as a fraction of its max velocity, 0 to ignore")
+var arriveDwellFlag = flag.Float64("arrivedwell", 0, "time the other arrival rules must hold for in seconds")
+var scheduleFlag = flag.Bool("schedule", false, "whether to schedule the position PID gains on the elbow angle and payload")
+var teleopFlag = flag.Bool("teleop", false, "drag the end of the arm with the mouse instead of adding points")
+var encNoiseFlag = flag.Float64("encnoise", 0, "standard deviation of the encoder noise in degrees")
+var kfFlag = flag.String("kf", "none", "state estimator: none, linear or ekf")
+var kfQFlag = flag.String("kfq", "0.0005,0.05,0.0005,0.05", "process noise standard deviations for angle1, vel1, angle2, vel2")
+var logFlag = flag.String("log", "", "CSV file to log the true and estimated state to")
 
 //create the arm struct to be used and run the graphics
 func main() {
+	flag.Parse()
+
+	//tune without the graphics
+	if *tuneFlag != 0 {
+		runTuning(*tuneFlag, *tunerFlag)
+		return
+	} //if
+
+	//sweep without the graphics
+	if *bodeFlag != 0 {
+		runFrequencyResponse(*bodeFlag, *bodeMethodFlag, *bodeAmpFlag, *bodeOutFlag)
+		return
+	} //if
+
 	//create a new canvas instance
 	c := canvas.NewCanvas(&canvas.CanvasConfig{
 		Width:     width,
@@ -58,10 +143,18 @@ func main() {
 
 	canAdd = true //can add mouse points
 
+	//commands typed into the terminal while running
+	go readConsole(os.Stdin)
+
 	//draw to the canvas
 	c.Draw(func(ctx *canvas.Context) {
-		if armloop.state != testing {
-			updateGoal(ctx)
+		if armloop.state != physicsTesting {
+			runConsole(&armloop)
+			if *teleopFlag {
+				updateTeleop(ctx)
+			} else {
+				updateGoal(ctx)
+			} //if
 			updateModel()
 		} else {
 			robotArm2.rest()
@@ -72,12 +165,171 @@ func main() {
 		}
 		draw(ctx)
 	})
+
+	//stop the outside controller once the window closes
+	if external != nil {
+		external.close()
+	} //if
 } //end main
 
 //MODEL
 
 //create the two-jointed arm
 func createArm2() {
+	robotArm2 = makeArm2()
+	robotArm2.payload = *payloadFlag
+
+	//outside forces on the end of the arm
+	if f, err := ParseFloats(*forceFlag); err == nil && len(f) == 2 {
+		robotArm2.extForce = Point{f[0], f[1]}
+	} else {
+		fmt.Println("invalid external force, not pushing")
+	} //if
+	if *surfaceFlag != "" {
+		if sf, err := ParseFloats(*surfaceFlag); err == nil && len(sf) == 6 && (sf[2] != 0 || sf[3] != 0) {
+			robotArm2.surface = newContactSurface(Point{sf[0], sf[1]}, Point{sf[2], sf[3]}, sf[4], sf[5])
+		} else {
+			fmt.Println("invalid surface, not adding one")
+		} //if
+	} //if
+
+	//more gain on the shoulder as the arm extends and on the elbow as the payload grows
+	if *scheduleFlag {
+		robotArm2.arm1.schedule = newGainSchedule(elbowFoldKey,
+			gainEntry{at: 0, kP: 2.50, kI: 0, kD: 0.05},
+			gainEntry{at: math.Pi / 2, kP: 2.00, kI: 0, kD: 0.04},
+			gainEntry{at: math.Pi, kP: 1.50, kI: 0, kD: 0.03})
+		robotArm2.arm2.schedule = newGainSchedule(payloadKey,
+			gainEntry{at: 0, kP: 1.75, kI: 0, kD: 0.02},
+			gainEntry{at: 10, kP: 2.50, kI: 0, kD: 0.03})
+	} //if
+
+	//joint limits
+	for i, limits := range []string{*limits1Flag, *limits2Flag} {
+		if limits == "" {
+			continue
+		} //if
+		l, err := ParseFloats(limits)
+		if err != nil || len(l) != 2 || l[0] > l[1] {
+			fmt.Println("invalid limits for joint", i+1)
+			continue
+		} //if
+		joint := robotArm2.arm1
+		if i == 1 {
+			joint = robotArm2.arm2
+		} //if
+		joint.setLimits(ToRadians(l[0]), ToRadians(l[1]))
+	} //loop
+
+	//a wrist holding a claw, weighing 2kg
+	if *wristFlag > 0 {
+		robotArm2.addWrist(*wristFlag, 2)
+	} //if
+
+	//the region the arm can reach once limits or obstacles cut into it
+	obstacles := parseObstacles()
+	robotArm2.obstacles = obstacles
+	if robotArm2.arm1.limited || robotArm2.arm2.limited || len(obstacles) > 0 {
+		reach = newWorkspace(robotArm2, obstacles, ToRadians(0.5), 0.03)
+	} //if
+
+	//rules for arriving at each goal
+	arrival = arrivalCriteria{jointTolerance: ToRadians(*arriveJointFlag), radius: *arriveRadiusFlag,
+		velocity: *arriveVelFlag, dwell: *arriveDwellFlag}
+
+	//set start of second joint to beginning of first joint
+	robotArm2.arm2.start = robotArm2.arm1.getEndPtPxl()
+
+	//state machine for the arm
+	armloop = newArmLoop(robotArm2)
+	configureControllers()
+	policy, err := parseIKPolicy(*ikFlag)
+	if err != nil {
+		fmt.Println(err, "- using closest")
+	} //if
+	armloop.ikFallback = *ikFallbackFlag
+	fallback, err := parseOrientFallback(*orientFallbackFlag)
+	if err != nil {
+		fmt.Println(err, "- keeping the heading")
+	} //if
+	if *ikSolverFlag == "numeric" {
+		armloop.solver = robotArm2.numericSolver()
+	} else {
+		if *ikSolverFlag != "analytic" {
+			fmt.Println("unknown IK solver", *ikSolverFlag, "- using analytic")
+		} //if
+		armloop.solver = analyticSolver{arm2: robotArm2, policy: policy, fallback: fallback}
+	} //if
+	if *splineFlag != "" {
+		opts, err := parseSplineOptions(*splineFlag, *splineSpaceFlag, *splineVelFlag)
+		if err != nil {
+			fmt.Println(err, "- stopping at each point")
+			*splineFlag = ""
+		} //if
+		armloop.spline = opts
+	} //if
+	ctrl, err := chooseController(*ctrlFlag, *ffFlag, *planFlag || *splineFlag != "")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	} //if
+	if err := armloop.setController(ctrl); err != nil { //keeps pid
+		fmt.Println(err, "- using pid")
+	} //if
+	if *toppFlag {
+		armloop.topp = &toppLimits{voltage: *toppVoltsFlag, current: *toppCurrentFlag}
+	} //if
+	if *planFlag {
+		armloop.planner = newPlanner(robotArm2, obstacles, time.Now().UnixNano())
+	} //if
+	if *reorderFlag {
+		fixed, err := parseFixedEnds(*reorderFixFlag)
+		if err != nil {
+			fmt.Println(err, "- fixing none")
+		} //if
+		reorder = &fixed
+	} //if
+
+	//imperfect encoders and an optional Kalman filter to estimate through them
+	noise := ToRadians(*encNoiseFlag)
+	armloop.enc1 = encoder{noise: noise}
+	armloop.enc2 = encoder{noise: noise}
+	if *kfFlag == "linear" || *kfFlag == "ekf" {
+		q, err := ParseFloats(*kfQFlag)
+		if err != nil || len(q) != 4 {
+			fmt.Println("invalid process noise, not estimating")
+		} else {
+			measNoise := []float64{noise, noise}
+			if noise == 0 { //the filter still needs some trust in the model
+				measNoise = []float64{ToRadians(0.01), ToRadians(0.01)}
+			} //if
+			armloop.estimator = newKalmanFilter(robotArm2, robotArm2.getState(), q, measNoise, *kfFlag == "ekf")
+		} //if
+	} //if
+	if *logFlag != "" {
+		f, err := os.Create(*logFlag)
+		if err != nil {
+			fmt.Println("could not create log:", err)
+		} else {
+			fmt.Fprintln(f, estimateHeader)
+			armloop.stateLog = f
+		} //if
+	} //if
+
+	//runs if the arm is in testing
+	if armloop.state == physicsTesting {
+		robotArm2.arm1.angle = ToRadians(0)
+		robotArm2.arm2.angle = ToRadians(0)
+		robotArm2.arm2.vel = 0
+		robotArm2.arm2.start = robotArm2.arm1.getEndPtPxl()
+
+		robotArm2.setArmColors(white)
+	} //if
+} //end createArm2
+
+//make a two-jointed arm with the default configuration
+//return - the arm at rest with both joints horizontal
+func makeArm2() Arm2 {
 	//PID constants
 	kP1 := 2.00
 	kI1 := 0.0
@@ -91,25 +343,133 @@ func createArm2() {
 	joint1 := NewArm(1.0, 30.0, 159.3, 2, kP1, kI1, kD1, "cim", 0)
 	joint2 := NewArm(0.8, 15.0, 159.3, 1, kP2, kI2, kD2, "cim", 0)
 	joint2.isSecondJoint = true
-	robotArm2.arm1 = joint1
-	robotArm2.arm2 = joint2
 
-	//set start of second joint to beginning of first joint
-	robotArm2.arm2.start = robotArm2.arm1.getEndPtPxl()
+	//feedforward from the motor model, no static friction in the simulation
+	joint1.ff = motorFFGains(joint1, 0)
+	joint2.ff = motorFFGains(joint2, 0)
 
-	//state machine for the arm
-	armloop = ArmLoop{arm2: robotArm2, state: waiting}
+	//velocity and current mode gains
+	joint1.velPID = pidcontroller{kP: 0.2}
+	joint2.velPID = pidcontroller{kP: 0.1}
+	joint1.curPID = pidcontroller{kI: 0.0005}
+	joint2.curPID = pidcontroller{kI: 0.0005}
 
-	//runs if the arm is in testing
-	if armloop.state == testing {
-		robotArm2.arm1.angle = ToRadians(0)
-		robotArm2.arm2.angle = ToRadians(0)
-		robotArm2.arm2.vel = 0
-		robotArm2.arm2.start = robotArm2.arm1.getEndPtPxl()
+	a2 := Arm2{arm1: joint1, arm2: joint2}
+	a2.update()
+	return a2
+} //end makeArm2
 
-		robotArm2.setArmColors(white)
+//Configure the registered controllers with the weights and limits from the command line
+func configureControllers() {
+	q, errQ := ParseFloats(*lqrQFlag)
+	r, errR := ParseFloats(*lqrRFlag)
+	if errQ != nil || errR != nil || len(q) != 4 || len(r) != 2 {
+		fmt.Println("invalid LQR weights, using the defaults")
+	} else {
+		ctrlSettings.lqrQ, ctrlSettings.lqrR = q, r
 	} //if
-} //end createArm2
+
+	if *mpcHorizonFlag < 1 {
+		fmt.Println("MPC horizon must be at least 1 time step, using 25")
+	} else {
+		ctrlSettings.mpcHorizon = *mpcHorizonFlag
+	} //if
+	ctrlSettings.mpcCurrent = *mpcCurrentFlag
+	ctrlSettings.mpcLimits = *mpcLimitsFlag
+
+	k, errK := ParseFloats(*impKFlag)
+	d, errD := ParseFloats(*impDFlag)
+	if errK != nil || errD != nil || len(k) != 2 || len(d) != 2 {
+		fmt.Println("invalid impedance gains, using the defaults")
+	} else {
+		ctrlSettings.impK, ctrlSettings.impD = k, d
+	} //if
+
+	//only one outside controller, shared by every switch to it
+	var ext *externalController
+	var err error
+	if *extCmdFlag != "" {
+		ext, err = startProcessController(robotArm2, *extCmdFlag)
+	} else if *extListenFlag != "" {
+		ext, err = listenForController(robotArm2, *extListenFlag)
+	} //if
+	if err != nil {
+		fmt.Println("external controller:", err)
+	} else if ext != nil {
+		external = ext
+		RegisterController("external", func(a2 Arm2) Controller { return ext })
+	} //if
+} //end configureControllers
+
+//Choose the controller to start with from the command line
+//string ctrl - controller picked by name
+//string ff - feedforward for the pid controller, gravity or id
+//bool follow - whether the moves are along trajectories only pid-id follows, such as planned paths and splines
+//return - name of the controller to use, or an error if it can't follow the trajectories
+func chooseController(ctrl, ff string, follow bool) (string, error) {
+	switch ff {
+	case "gravity":
+	case "id": //the older way to pick pid-id
+		if ctrl == "pid" {
+			ctrl = "pid-id"
+		} //if
+	default:
+		fmt.Println("unknown feedforward", ff, "- using gravity")
+	} //switch
+
+	if follow && ctrl == "pid" { //the default, so it follows them too
+		ctrl = "pid-id"
+	} //if
+	if follow && ctrl != "pid-id" {
+		return "", fmt.Errorf("%s can't follow planned paths or splines, use pid or pid-id", ctrl)
+	} //if
+	return ctrl, nil
+} //end chooseController
+
+//Parse the obstacles from the command line
+//return - the floor if it is blocking, and each round obstacle
+func parseObstacles() []obstacle {
+	var obstacles []obstacle
+	if *floorFlag {
+		obstacles = append(obstacles, floorObstacle{0})
+	} //if
+	if *obstaclesFlag == "" {
+		return obstacles
+	} //if
+
+	for _, o := range strings.Split(*obstaclesFlag, ";") {
+		c, err := ParseFloats(o)
+		if err != nil || len(c) != 3 || c[2] <= 0 {
+			fmt.Println("invalid obstacle", o)
+			continue
+		} //if
+		obstacles = append(obstacles, circleObstacle{Point{c[0], c[1]}, c[2]})
+	} //loop
+	return obstacles
+} //end parseObstacles
+
+//Make a goal for a point with the approach angle from the command line
+//Point p - goal point
+//return - the goal, oriented if there is an approach angle
+func makeGoal(p Point) Goal {
+	if angle, err := ParseFloats(*approachFlag); err == nil && len(angle) == 1 {
+		return orientedGoal(p, ToRadians(angle[0]))
+	} //if
+	return pointGoal(p)
+} //end makeGoal
+
+//Keep a goal point where the arm can reach
+//Point p - point to clamp
+//return - the closest point to it the arm can reach
+func clampGoal(p Point) Point {
+	if reach != nil {
+		return reach.clamp(p)
+	} //if
+	if robotArm2.arm3 != nil { //the claw reaches a little further
+		return ClampToCSpace(p, robotArm2.arm1.length, robotArm2.arm2.length+robotArm2.arm3.length)
+	} //if
+	return ClampToCSpace(p, robotArm2.arm1.length, robotArm2.arm2.length)
+} //end clampGoal
 
 //add the mouse click coordinates as points for the arm
 //*canvas.Context ctx - used for drawing
@@ -117,12 +477,15 @@ func updateGoal(ctx *canvas.Context) {
 	//scale the point from pixel to cartesian coordinates
 	ghost = scalePoint(Point{ctx.Mouse.X - float64(width)/2, ctx.Mouse.Y}, 1.0/float64(pixelToMeters))
 	//clamp the point to the configuration space of the arm
-	ghost = ClampToCSpace(ghost, robotArm2.arm1.length, robotArm2.arm2.length)
+	ghost = clampGoal(ghost)
 
 	//add points with mouse click
 	if canAdd { //if user can add points
 		if ctx.IsMouseDragged { //mouse click
-			pts = append(pts, ghost) //add it to the list of goals
+			pts = append(pts, makeGoal(ghost)) //add it to the list of goals
+			if reorder != nil {
+				reorderPoints()
+			} //if
 
 			//canAdd and the Timer are used to prevent multiple points be added during one click
 			canAdd = false
@@ -136,29 +499,163 @@ func updateGoal(ctx *canvas.Context) {
 		}() //timer goroutine
 	} //if
 
+	//show the order once it has been found
+	if reorder != nil {
+		applyReorder()
+	} //if
+
 	//set goal
-	if len(pts) != 0 { //if lists isn't empty
+	if queued() > pointIndex { //if there is a point the arm can move to
 		if pts[pointIndex] != armloop.goal && armloop.state != goalTracking { //if last point in list isn't already the goal and the arm is finished
-			time.Sleep(time.Millisecond * 250) //delay before setting goal
-			armloop.setGoal(pts[pointIndex])   //set the next point as the arm's goal
+			if *splineFlag != "" && armloop.followsTrajectories() { //through every queued point without stopping
+				armloop.setWaypoints(pts[pointIndex:queued()], arrival)
+				pointIndex = queued() - 1
+			} else {
+				time.Sleep(time.Millisecond * 250)                   //delay before setting goal
+				armloop.setGoalWithArrival(pts[pointIndex], arrival) //set the next point as the arm's goal
+			} //if
 		} //if
 	} //if
 } //end updateGoal
 
+//Find the first queued point the arm hasn't started moving to
+//return - index of the point in pts
+func pendingIndex() int {
+	if len(pts) > 0 && pts[pointIndex] == armloop.goal { //already moving to it or there
+		return pointIndex + 1
+	} //if
+	return pointIndex
+} //end pendingIndex
+
+//Get how many of the queued points the arm can move to
+//return - every point, or only those that have been run while reordering
+func queued() int {
+	if reorder == nil {
+		return len(pts)
+	} //if
+	return released
+} //end queued
+
+//Find the first queued point that can still be reordered
+//return - index of the point in pts
+func orderIndex() int {
+	if first := pendingIndex(); first > released {
+		return first
+	} //if
+	return released
+} //end orderIndex
+
+//Reorder the queued points that haven't been run off the draw loop, to visit them all in the least predicted time
+func reorderPoints() {
+	if reordering { //order them all once this order is found
+		reorderAgain = true
+		return
+	} //if
+	first := orderIndex()
+	if first >= len(pts) {
+		return
+	} //if
+
+	//start from the point before, or where the arm is if it isn't headed anywhere
+	loop := armloop.orderingCopy()
+	goals := append([]Goal{}, pts[first:]...)
+	headed := first > pointIndex
+	var before Goal
+	if headed {
+		before = pts[first-1]
+	} //if
+	fixed := *reorder
+
+	reordering = true
+	go func() {
+		start := ikSolution{loop.arm2.arm1.angle, loop.arm2.arm2.angle}
+		if headed {
+			start, _, _ = loop.solveGoalFrom(before, start)
+		} //if
+		ordered, best, given := loop.orderGoals(start, goals, fixed)
+		reorders <- reorderResult{first, goals, ordered, best, given, loop.moveTimes}
+	}() //ordering goroutine
+} //end reorderPoints
+
+//Put the queued points in the order found off the draw loop, if they are still waiting in the order it was given
+func applyReorder() {
+	var r reorderResult
+	select {
+	case r = <-reorders:
+	default: //still ordering, or nothing to order
+		return
+	} //select
+	reordering = false
+	armloop.moveTimes = r.moveTimes
+
+	waiting := r.first == orderIndex() && len(pts) >= r.first+len(r.goals)
+	for i := 0; waiting && i < len(r.goals); i++ {
+		waiting = pts[r.first+i] == r.goals[i]
+	} //loop
+	if waiting {
+		copy(pts[r.first:], r.ordered)
+		cycleTime = r.best
+		fmt.Printf("visiting %d points takes %.2fs, %.2fs less than in the order they were added - type go to run them\n",
+			len(r.ordered), r.best, r.given-r.best)
+	} else { //the queue changed, so order it as it is now
+		reorderAgain = true
+	} //if
+
+	if reorderAgain {
+		reorderAgain = false
+		reorderPoints()
+	} //if
+} //end applyReorder
+
+//Let the arm move to every queued point once their order has been found
+//return - an error if they are still being reordered
+func releasePoints() error {
+	if reordering || reorderAgain {
+		return errors.New("still reordering the points, try again once the order is shown")
+	} //if
+	released = len(pts)
+	return nil
+} //end releasePoints
+
+//drive the end of the arm towards the mouse while it is held down
+//*canvas.Context ctx - used for the mouse
+func updateTeleop(ctx *canvas.Context) {
+	ghost = mouseToCartesian(ctx.Mouse)
+	ghost = clampGoal(ghost)
+
+	target := &ghost
+	if !ctx.IsMouseDragged { //hold still when the mouse is let go
+		target = nil
+	} //if
+	armloop.setRateSource(targetRateSource(target, 4, 1.5), 0.1)
+} //end updateTeleop
+
 //Update the arm's state machine
 func updateModel() {
 	//update the state for the state machine
 	if robotArm2.isStopped() { //if both joints are stopped
 		armloop.setState(finished) //set the state to finished
 
-		if len(pts)-1 > pointIndex { //if there is another point to move to
+		if queued()-1 > pointIndex { //if there is another point to move to
 			pointIndex++ //request to move to new goal point
 		} //if
+	} else if armloop.state == unreachable && queued()-1 > pointIndex { //skip the goal that can't be reached
+		pointIndex++
+	} else if commanded() && queued() > commandPts && queued()-1 > pointIndex { //leave the commands for a point added since
+		pointIndex++
 	} //if
 
 	armloop.onLoop() //move the arm
+	if !commanded() {
+		commandPts = queued() //points queued before any command that starts next
+	} //if
 } //end updateModel
 
+//Get whether the joints or the end point are being commanded directly instead of tracking points
+func commanded() bool {
+	return armloop.state == commanding || armloop.state == cartesianRate
+} //end commanded
+
 //DRAWING
 
 //Draw to the scree
@@ -170,6 +667,12 @@ func draw(ctx *canvas.Context) {
 
 	drawCSpace(ctx)  //draw the configuration space of the arm
 	drawPoints(ctx)  //draw all the points the robot can move to
+	drawPath(ctx)    //draw the path planned to the goal
+	drawOrder(ctx)   //draw the order the queued points will be visited in
+	drawSurface(ctx) //draw the surface the arm can press against
+	if *ellipseFlag {
+		drawConditioning(ctx) //draw how well the arm can move its end point
+	} //if
 	drawGhost(ctx)   //draw a point based on mouse location to show potential goal
 	displayData(ctx) //display the data to the screen
 	drawArm2(ctx)    //draw the 2-jointed arm to the screen
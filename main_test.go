@@ -6,19 +6,29 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"math"
+	"math/rand"
+	"strings"
 	"testing"
+	"time"
 )
 
 //if the forward kinematics produced with the inverse kinematics angles is not within a tolerance, fail the test
 func TestIK(t *testing.T) {
 	target := Point{0.375, 1.0}
-	a1, a2 := InverseKinematics(target, 1.0, 0.8, 0, 0)
+	a1, a2, err := InverseKinematics(target, 0, 0, 1.0, 0.8)
+	if err != nil {
+		t.Fatal("Point should be reachable:", err)
+	}
 	p2 := forwardKinematics(1.0, 0.8, a1, a2)
 
 	t.Log("Forward kinematics produced: (a1, a2, goal point)", ToDegrees(a1), ToDegrees(a2), p2.x, p2.y)
 
-	if !WithinBounds(target, p2, 1.0) {
+	if !WithinBounds(target, p2, 0.001) {
 		t.Error("Angles do not produce point")
 	}
 } //end testIK
@@ -106,3 +116,1318 @@ func forwardKinematics(a1, a2, q1, q2 float64) Point {
 
 	return p2
 } //end forward kinematics
+
+//both joints should hold still, with and without a payload, with only the two-joint feedforward applied
+func TestFFArm2Holds(t *testing.T) {
+	for _, payload := range []float64{0, 5} {
+		arm := makeArm2().withAngles(ToRadians(30), ToRadians(45))
+		arm.payload = payload
+		arm.update()
+
+		//run for two seconds
+		for i := 0; i < 2*fps; i++ {
+			arm.arm1.voltage, arm.arm2.voltage = calcFFArm2(arm, 0, 0, 0, 0)
+			arm.arm1.update()
+			arm.arm2.update()
+			arm.update()
+		} //loop
+
+		if math.Abs(arm.arm1.angle-ToRadians(30)) > ToRadians(0.1) {
+			t.Error("First joint drooped with a payload of", payload, "kg by (degrees):", ToDegrees(ToRadians(30)-arm.arm1.angle))
+		}
+
+		if math.Abs(arm.arm2.angle-ToRadians(45)) > ToRadians(0.1) {
+			t.Error("Second joint drooped with a payload of", payload, "kg by (degrees):", ToDegrees(ToRadians(45)-arm.arm2.angle))
+		}
+	} //loop
+}
+
+//the inverse dynamics feedforward alone should follow a trajectory to its end
+func TestIDFFTracksTrajectory(t *testing.T) {
+	arm := makeArm2()
+	arm.update()
+
+	traj := arm.newPathTrajectory(jointPath{{0, 0}, {ToRadians(60), ToRadians(-45)}})
+
+	//run until the trajectory ends
+	for i := 1; float64(i-1)*dt <= traj.duration(); i++ {
+		time := float64(i-1) * dt
+		sp1, sp2 := traj.sample(time)
+		arm.arm1.voltage, arm.arm2.voltage = calcIDFFArm2(arm, sp1, sp2)
+		arm.arm1.update()
+		arm.arm2.update()
+		arm.update()
+	} //loop
+
+	t.Log("Inverse dynamics ended at (a1, a2):", arm.arm1.getAngleDeg(), arm.arm2.getAngleDeg())
+
+	if math.Abs(arm.arm1.getAngleDeg()-60) > 2 || math.Abs(arm.arm2.getAngleDeg()+45) > 2 {
+		t.Error("Feedforward did not follow the trajectory")
+	}
+}
+
+//the scalar Riccati equation with A=B=Q=R=1 is solved by the golden ratio
+func TestDARE(t *testing.T) {
+	one := newMatrix(1, 1, 1)
+	P, err := solveDARE(one, one, one, one)
+	if err != nil {
+		t.Fatal("Riccati equation did not solve:", err)
+	}
+
+	if math.Abs(P.at(0, 0)-(1+math.Sqrt(5))/2) > 1e-6 {
+		t.Error("Wrong solution to the Riccati equation:", P.at(0, 0))
+	}
+}
+
+//LQR should drive both joints to a goal
+func TestLQRReachesGoal(t *testing.T) {
+	arm := makeArm2()
+	arm.update()
+
+	goal := ArmState{ToRadians(70), 0, ToRadians(-60), 0}
+	lqr, err := newLQR(arm, goal, []float64{3283, 4, 3283, 4}, []float64{0.0069, 0.0069})
+	if err != nil {
+		t.Fatal("Could not create LQR:", err)
+	}
+
+	for i := 0; i < 5*fps; i++ {
+		arm.arm1.voltage, arm.arm2.voltage = lqr.calcLQR(arm, arm.getState())
+		arm.arm1.update()
+		arm.arm2.update()
+		arm.update()
+	} //loop
+
+	t.Log("LQR ended at (a1, a2):", arm.arm1.getAngleDeg(), arm.arm2.getAngleDeg())
+
+	if math.Abs(arm.arm1.getAngleDeg()-70) > 1 || math.Abs(arm.arm2.getAngleDeg()+60) > 1 {
+		t.Error("LQR did not reach the goal")
+	}
+}
+
+//the Kalman filter estimate should be closer to the true angle than the noisy encoder
+func TestKalmanFilter(t *testing.T) {
+	for _, extended := range []bool{false, true} {
+		arm := makeArm2().withAngles(ToRadians(30), ToRadians(20))
+		arm.update()
+
+		noise := ToRadians(1)
+		enc := encoder{noise: noise, rng: rand.New(rand.NewSource(1))}
+		kf := newKalmanFilter(arm, arm.getState(), []float64{0.0005, 0.05, 0.0005, 0.05}, []float64{noise, noise}, extended)
+
+		measErr, estErr := 0.0, 0.0
+		for i := 0; i < 3*fps; i++ {
+			//swing the arm with a varying voltage on top of the feedforward
+			ff1, ff2 := calcFFArm2(arm, 0, 0, 0, 0)
+			arm.arm1.voltage = ff1 + 2*math.Sin(float64(i)*dt*3)
+			arm.arm2.voltage = ff2 + 2*math.Cos(float64(i)*dt*3)
+
+			z := enc.measure(arm.arm1.angle)
+			kf.correct(z, enc.measure(arm.arm2.angle))
+			measErr += math.Abs(z - arm.arm1.angle)
+			estErr += math.Abs(kf.x[0] - arm.arm1.angle)
+
+			kf.predict(arm, arm.arm1.voltage, arm.arm2.voltage)
+			arm.arm1.update()
+			arm.arm2.update()
+			arm.update()
+		} //loop
+
+		t.Log("Mean error of measurement and estimate (extended, degrees):", extended,
+			ToDegrees(measErr/(3*float64(fps))), ToDegrees(estErr/(3*float64(fps))))
+
+		if estErr >= measErr {
+			t.Error("Estimate is no better than the measurement")
+		}
+	} //loop
+
+	//the estimate columns are left empty without an estimator
+	var log strings.Builder
+	logEstimate(&log, 0.02, ArmState{1, 2, 3, 4}, nil)
+	logEstimate(&log, 0.04, ArmState{1, 2, 3, 4}, &ArmState{5, 6, 7, 8})
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "4.000000,,,,") || !strings.HasSuffix(lines[1], ",8.000000") {
+		t.Error("Should only log an estimate when there is one, got", lines)
+	}
+}
+
+//MPC should reach a goal without asking for more than the voltage and current limits
+func TestMPCReachesGoal(t *testing.T) {
+	arm := makeArm2()
+	arm.update()
+
+	goal := ArmState{ToRadians(60), 0, ToRadians(-45), 0}
+	mpc := newMPC(25, 5, []float64{3283, 4, 3283, 4}, []float64{0.0069, 0.0069})
+	mpc.currentLimit = 100
+
+	for i := 0; i < 4*fps; i++ {
+		v1, v2 := mpc.calcMPC(arm, arm.getState(), goal)
+		if math.Abs(v1) > MaxVoltage || math.Abs(v2) > MaxVoltage {
+			t.Fatal("Voltage over the limit:", v1, v2)
+		}
+		if math.Abs(arm.arm1.calcCurrent(v1, arm.arm1.vel)) > 100+1e-9 || math.Abs(arm.arm2.calcCurrent(v2, arm.arm2.vel)) > 100+1e-9 {
+			t.Fatal("Current over the limit:", arm.arm1.calcCurrent(v1, arm.arm1.vel), arm.arm2.calcCurrent(v2, arm.arm2.vel))
+		}
+
+		arm.arm1.voltage, arm.arm2.voltage = v1, v2
+		arm.arm1.update()
+		arm.arm2.update()
+		arm.update()
+	} //loop
+
+	t.Log("MPC ended at (a1, a2):", arm.arm1.getAngleDeg(), arm.arm2.getAngleDeg())
+
+	if math.Abs(arm.arm1.getAngleDeg()-60) > 1 || math.Abs(arm.arm2.getAngleDeg()+45) > 1 {
+		t.Error("MPC did not reach the goal")
+	}
+
+	//a horizon under one time step still looks one step ahead
+	short := newMPC(0, 5, []float64{3283, 4, 3283, 4}, []float64{0.0069, 0.0069})
+	if short.horizon != 1 || len(short.u) != 1 {
+		t.Error("Should look at least one step ahead, horizon", short.horizon, "blocks", len(short.u))
+	}
+	short.calcMPC(arm, arm.getState(), goal)
+}
+
+//every registered controller should drive the arm to a goal through the interface
+func TestControllers(t *testing.T) {
+	if _, err := NewController("not a controller", Arm2{}); err == nil {
+		t.Error("Unknown controller should be an error")
+	}
+
+	for _, name := range ControllerNames() {
+		arm := makeArm2()
+		arm.update()
+		c, _ := NewController(name, arm)
+
+		goal := ArmState{ToRadians(45), 0, ToRadians(30), 0}
+		c.reset(arm.getState(), goal, 0)
+		for i := 0; i < 5*fps; i++ {
+			arm.arm1.voltage, arm.arm2.voltage = c.calculate(arm.getState(), goal, float64(i)*dt)
+			arm.arm1.update()
+			arm.arm2.update()
+			arm.update()
+		} //loop
+
+		t.Log(name, "ended at (a1, a2):", arm.arm1.getAngleDeg(), arm.arm2.getAngleDeg())
+
+		//impedance control only pulls on the end point, so either elbow configuration reaches it
+		if name == "impedance" {
+			tip, target := arm.calcEndPoint(arm.arm1.angle, arm.arm2.angle), arm.calcEndPoint(goal[0], goal[2])
+			if math.Hypot(tip.x-target.x, tip.y-target.y) > 0.01 {
+				t.Error(name, "did not reach the goal")
+			}
+			continue
+		}
+
+		if math.Abs(arm.arm1.getAngleDeg()-45) > 1 || math.Abs(arm.arm2.getAngleDeg()-30) > 1 {
+			t.Error(name, "did not reach the goal")
+		}
+	} //loop
+}
+
+//controllers should be created with the current settings and switched by typing their names while running
+func TestSwitchController(t *testing.T) {
+	saved := ctrlSettings
+	defer func() { ctrlSettings = saved }()
+	ctrlSettings.mpcHorizon = 10
+	if c, _ := NewController("mpc", makeArm2()); c.(*mpccontroller).horizon != 10 {
+		t.Error("MPC should be created with the horizon from the settings")
+	}
+	ctrlSettings.impK = []float64{100, 200}
+	if c, _ := NewController("impedance", makeArm2()); c.(*impedancecontroller).stiffness[1] != 200 {
+		t.Error("Impedance should be created with the stiffness from the settings")
+	}
+
+	//a new loop drives the arm with pid until another controller is picked
+	loop := newArmLoop(makeArm2())
+	if _, ok := loop.controller.(*pidFFController); !ok || loop.controllerName != "pid" {
+		t.Error("New loop should start with pid, has", loop.controllerName)
+	}
+	loop.setGoal(loop.arm2.calcEndPoint(ToRadians(60), ToRadians(-30)))
+	for i := 0; i < fps/2; i++ {
+		loop.onLoop()
+	} //loop
+
+	go readConsole(strings.NewReader("ctrl lqr\n\nctrl nothing\n"))
+	for i := 0; i < 3; i++ { //wait for every line to be read
+		loop.runCommand(<-consoleLines)
+	} //loop
+	if loop.controllerName != "lqr" {
+		t.Error("Should have switched to lqr and kept it, have", loop.controllerName)
+	}
+	if err := loop.runCommand("fly"); err == nil {
+		t.Error("Unknown command should be an error")
+	}
+
+	//the new controller picks up the move where the last one left off
+	for i := 0; i < 4*fps && !loop.arm2.isStopped(); i++ {
+		loop.onLoop()
+	} //loop
+	if tip := loop.arm2.calcEndPoint(loop.arm2.arm1.angle, loop.arm2.arm2.angle); !loop.arm2.isStopped() || !WithinBounds(tip, loop.goal.point, 0.05) {
+		t.Error("Should reach the goal after switching, at", tip)
+	}
+}
+
+//an external controller should get the state each step and have its voltages used
+func TestExternalController(t *testing.T) {
+	simR, ctrlW := io.Pipe()
+	ctrlR, simW := io.Pipe()
+	c := newExternalController(makeArm2(), simR, simW, nil)
+
+	//outside controller that replies with the goal angles as voltages
+	var sent extMessage
+	go func() {
+		dec := json.NewDecoder(ctrlR)
+		for {
+			var msg extMessage
+			if dec.Decode(&msg) != nil {
+				return
+			}
+			if msg.Type == "step" {
+				sent = msg
+				fmt.Fprintf(ctrlW, "{\"voltages\": [%f, %f]}\n", msg.Goal[0], msg.Goal[2])
+			}
+		} //loop
+	}()
+
+	goal := ArmState{1, 0, 2, 0}
+	c.reset(ArmState{}, goal, 0)
+	v1, v2 := c.calculate(ArmState{}, goal, dt)
+	if v1 != 1 || v2 != 2 || c.err != nil {
+		t.Error("Wrong voltages from the external controller:", v1, v2, c.err)
+	}
+
+	//straight out the arm is singular, and the conditioning is sent with the state
+	if sent.Manipulability != 0 || sent.InvCondition != 0 || sent.Near != extended.String() {
+		t.Error("Should be sent the conditioning of a singular arm:", sent.Manipulability, sent.InvCondition, sent.Near)
+	}
+
+	//a reply without both voltages is an error, not 0V
+	c = newExternalController(makeArm2(), strings.NewReader("{\"voltages\": [3]}\n"), io.Discard, nil)
+	c.calculate(ArmState{}, goal, dt)
+	if c.err == nil {
+		t.Error("Reply missing a voltage should be an error")
+	}
+
+	//a process that only echoes the messages back is stopped and reaped
+	proc, err := startProcessController(makeArm2(), "cat")
+	if err != nil {
+		t.Skip("no cat to run:", err)
+	}
+	cmd := proc.proc
+	proc.calculate(ArmState{}, goal, dt)
+	if proc.err == nil || proc.proc != nil || cmd.ProcessState == nil {
+		t.Error("Process should be stopped and waited on after a bad reply", proc.err)
+	}
+}
+
+//the D term should act on the change in error, with no kick or sum carried over to a new goal
+func TestPIDDerivative(t *testing.T) {
+	pid := pidcontroller{kD: 1}
+	if out := pid.calcPID(1, 0, 0); out != 0 {
+		t.Error("First loop to a goal should have no derivative, got", out)
+	}
+	if out := pid.calcPID(1, 0.25, 0); math.Abs(out+0.25) > 1e-9 {
+		t.Error("D term should be the change in error of -0.25, got", out)
+	}
+
+	//a new goal starts from its own error
+	pid = pidcontroller{kP: 1, kI: 1, kD: 1}
+	for i := 0; i < 10; i++ {
+		pid.calcPID(1, 0, 0)
+	}
+	c := &pidFFController{arm2: makeArm2()}
+	c.arm2.arm1.pid = pid
+	c.reset(ArmState{}, ArmState{-1, 0, 0, 0}, 0)
+	if out := c.arm2.arm1.pid.calcPID(-1, 0, 0); math.Abs(out+2) > 1e-9 {
+		t.Error("New goal should only have its own P and I, got", out)
+	}
+}
+
+//Nelder-Mead should find the minimum of a simple bowl
+func TestNelderMead(t *testing.T) {
+	bowl := func(x []float64) float64 { return (x[0]-1)*(x[0]-1) + 10*(x[1]+2)*(x[1]+2) }
+	x, f := nelderMead(bowl, []float64{0, 0}, []float64{1, 1}, 500)
+
+	if math.Abs(x[0]-1) > 1e-3 || math.Abs(x[1]+2) > 1e-3 {
+		t.Error("Wrong minimum (x, y, f):", x[0], x[1], f)
+	}
+}
+
+//the relay should find the ultimate point of each joint, and both tuners should give gains that settle without overshoot
+func TestTuning(t *testing.T) {
+	setpoint := ArmState{ToRadians(30), 0, ToRadians(30), 0}
+	for joint := 1; joint <= 2; joint++ {
+		ku, tu, err := relayTune(joint, setpoint, 2, ToRadians(1))
+		if err != nil {
+			t.Fatal("Relay failed on joint", joint, err)
+		}
+		if ku < 10 || ku > 5000 || tu < 5*dt || tu > 2 {
+			t.Error("Unreasonable ultimate point (joint, Ku, Tu):", joint, ku, tu)
+		}
+
+		a2 := makeArm2()
+		initial := a2.arm1.pid
+		if joint == 2 {
+			initial = a2.arm2.pid
+		}
+		target := setpoint[joint*2-2] + ToRadians(30)
+		tuned := map[string]pidcontroller{"relay": znGains(ku, tu), "nm": optimizeGains(joint, initial, setpoint, target)}
+		for method, gains := range tuned {
+			for _, step := range []float64{30, -30, 5} {
+				m := calcStepMetrics(simulateStep(joint, gains, setpoint, setpoint[joint*2-2]+ToRadians(step)))
+				if !(m.settleTime < 1) || m.overshoot > 5 {
+					t.Error("Joint", joint, "tuned with", method, "should settle within 1s and 5% overshoot stepping", step, "deg:", m)
+				}
+			}
+		}
+	}
+}
+
+//gains should be interpolated between entries, held past the ends of the table and used by commanded joints
+func TestGainSchedule(t *testing.T) {
+	gs := newGainSchedule(payloadKey, gainEntry{at: 10, kP: 3, kD: 0.1}, gainEntry{at: 0, kP: 1, kD: 0.05})
+
+	cases := []struct{ at, kP float64 }{{-5, 1}, {0, 1}, {2.5, 1.5}, {10, 3}, {20, 3}}
+	for _, c := range cases {
+		if kP := gs.lookup(c.at).kP; math.Abs(kP-c.kP) > 1e-9 {
+			t.Error("Wrong kP at", c.at, "expected", c.kP, "got", kP)
+		}
+	}
+
+	//commanded joints should use the scheduled gains too
+	loop := newArmLoop(makeArm2())
+	loop.arm2.arm2.schedule = gs
+	loop.arm2.payload = 5
+	loop.setJointCommand(2, positionMode, ToRadians(10))
+	loop.onLoop()
+	if math.Abs(loop.arm2.arm2.pid.kP-2) > 1e-9 {
+		t.Error("Commanded joint should use the scheduled kP of 2, has", loop.arm2.arm2.pid.kP)
+	}
+}
+
+//velocity and current modes should reach their setpoints
+func TestJointModes(t *testing.T) {
+	arm := makeArm2()
+	for i := 0; i < fps; i++ {
+		tau1, tau2 := arm.calcGravTorques(arm.arm1.angle, arm.arm2.angle)
+		arm.arm1.command(jointCommand{velocityMode, 0.5}, tau1)
+		arm.arm2.command(jointCommand{currentMode, 20}, tau2)
+		arm.update()
+	} //loop
+
+	if math.Abs(arm.arm1.vel-0.5) > 0.01 {
+		t.Error("Velocity mode did not reach 0.5 rad/s:", arm.arm1.vel)
+	}
+	if math.Abs(arm.arm2.current-20) > 0.5 {
+		t.Error("Current mode did not reach 20 A:", arm.arm2.current)
+	}
+}
+
+//typing a joint command should switch to commanding, holding the other joint where it was
+func TestJointCommand(t *testing.T) {
+	loop := newArmLoop(makeArm2())
+	held := loop.arm2.arm1.angle
+
+	if err := loop.runCommand("joint 2 velocity 30"); err != nil || loop.state != commanding || loop.arm2.isStopped() {
+		t.Fatal("Should be commanding and moving after a joint command, in", loop.state, err)
+	}
+	for _, line := range []string{"joint 3 velocity 30", "joint 2 torque 30", "joint 2 velocity fast"} {
+		if err := loop.runCommand(line); err == nil {
+			t.Error("Bad joint command should be an error:", line)
+		}
+	}
+	for i := 0; i < fps; i++ {
+		loop.onLoop()
+	} //loop
+
+	if math.Abs(loop.arm2.arm2.vel-ToRadians(30)) > 0.01 {
+		t.Error("Second joint should turn at 30 degrees/second, at", ToDegrees(loop.arm2.arm2.vel))
+	}
+	if math.Abs(loop.arm2.arm1.angle-held) > ToRadians(1) {
+		t.Error("First joint should be held, moved by (degrees):", ToDegrees(loop.arm2.arm1.angle-held))
+	}
+}
+
+//a command should hold the arm until a point is added after it, even with points already queued
+func TestCommandKeepsQueue(t *testing.T) {
+	savedPts, savedIndex, savedArm, savedLoop := pts, pointIndex, robotArm2, armloop
+	defer func() { pts, pointIndex, robotArm2, armloop = savedPts, savedIndex, savedArm, savedLoop }()
+	robotArm2 = makeArm2()
+	armloop = newArmLoop(robotArm2)
+	pts, pointIndex = []Goal{pointGoal(Point{1, 1}), pointGoal(Point{1.2, 0.5})}, 0
+	updateModel()
+
+	armloop.runCommand("joint 2 velocity 30")
+	for i := 0; i < fps; i++ {
+		updateModel()
+	} //loop
+	if armloop.state != commanding || pointIndex != 0 {
+		t.Fatal("Should keep commanding with points already queued, in", armloop.state, "at point", pointIndex)
+	}
+
+	pts = append(pts, pointGoal(Point{0.5, 1}))
+	updateModel()
+	if pointIndex != 1 {
+		t.Error("A new point should move on to the next one, at point", pointIndex)
+	}
+}
+
+//reordered points should wait to be run, ordered off the draw loop
+func TestReorderWaits(t *testing.T) {
+	savedPts, savedIndex, savedArm, savedLoop := pts, pointIndex, robotArm2, armloop
+	savedReorder, savedReleased := reorder, released
+	defer func() {
+		pts, pointIndex, robotArm2, armloop = savedPts, savedIndex, savedArm, savedLoop
+		reorder, released = savedReorder, savedReleased
+	}()
+	robotArm2 = makeArm2()
+	armloop = newArmLoop(robotArm2)
+	reorder, released = new(fixedEnds), 0
+	pts, pointIndex = nil, 0
+	for _, q1 := range []float64{0.3, 2.8, 0.5, 2.6} {
+		pts = append(pts, pointGoal(robotArm2.calcEndPoint(q1, 0.3)))
+		reorderPoints()
+	}
+	clicked := append([]Goal{}, pts...)
+
+	if queued() != 0 {
+		t.Error("No point should be run before go, queued", queued())
+	}
+	if err := releasePoints(); err == nil {
+		t.Error("Should refuse to run the points while ordering them")
+	}
+	for i := 0; reordering || reorderAgain; i++ {
+		if i == 1000 {
+			t.Fatal("Ordering the points never finished")
+		}
+		time.Sleep(time.Millisecond)
+		applyReorder()
+	}
+	if pts[1] != clicked[2] || pts[3] != clicked[1] {
+		t.Error("Should group the points by side before running them:", pts)
+	}
+
+	if err := releasePoints(); err != nil || queued() != len(pts) {
+		t.Error("Go should run every point, queued", queued(), err)
+	}
+}
+
+//joint rates from the Jacobian should move the end point at the commanded velocity
+func TestJointRates(t *testing.T) {
+	arm := makeArm2()
+	q1, q2 := ToRadians(30), ToRadians(60)
+	v := Point{0.2, -0.1}
+
+	qd1, qd2 := arm.calcJointRates(q1, q2, v, 0.1)
+	start := arm.calcEndPoint(q1, q2)
+	end := arm.calcEndPoint(q1+qd1*1e-4, q2+qd2*1e-4)
+	moved := Point{(end.x - start.x) / 1e-4, (end.y - start.y) / 1e-4}
+
+	if math.Hypot(v.x-moved.x, v.y-moved.y) > 1e-3 {
+		t.Error("End point moved at", moved.x, moved.y, "instead of", v.x, v.y)
+	}
+
+	//fully extended, the damping should keep the rates finite
+	qd1, qd2 = arm.calcJointRates(0, 0, Point{0.5, 0}, 0.1)
+	if math.IsNaN(qd1) || math.IsInf(qd1, 0) || math.IsNaN(qd2) || math.IsInf(qd2, 0) {
+		t.Error("Joint rates blew up at the singularity:", qd1, qd2)
+	}
+}
+
+//typed end point velocities and lines should be followed by resolved-rate control
+func TestRateCommands(t *testing.T) {
+	loop := newArmLoop(makeArm2())
+	loop.arm2.arm1.angle, loop.arm2.arm2.angle = ToRadians(30), ToRadians(60) //away from the straight-out singularity
+	loop.arm2.update()
+	start := loop.arm2.calcEndPoint(loop.arm2.arm1.angle, loop.arm2.arm2.angle)
+
+	if err := loop.runCommand("jog 0.2 0"); err != nil || loop.state != cartesianRate {
+		t.Fatal("Should be in cartesianRate after jogging, in", loop.state, err)
+	}
+	for i := 0; i < fps/2; i++ {
+		loop.onLoop()
+	} //loop
+	if tip := loop.arm2.calcEndPoint(loop.arm2.arm1.angle, loop.arm2.arm2.angle); math.Abs(tip.x-start.x-0.1) > 0.02 || math.Abs(tip.y-start.y) > 0.02 {
+		t.Error("Jog should move 0.1m right, moved", tip.x-start.x, tip.y-start.y)
+	}
+
+	//a point along the line reached in time
+	goal := loop.arm2.calcEndPoint(ToRadians(60), ToRadians(-30))
+	if err := loop.runCommand(fmt.Sprintf("line %f %f 1", goal.x, goal.y)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3*fps/2; i++ {
+		loop.onLoop()
+	} //loop
+	if tip := loop.arm2.calcEndPoint(loop.arm2.arm1.angle, loop.arm2.arm2.angle); !WithinBounds(tip, goal, 0.02) {
+		t.Error("Line should end at", goal, "ended at", tip)
+	}
+
+	for _, line := range []string{"jog 1", "jog a b", "line 1 1 0"} {
+		if err := loop.runCommand(line); err == nil {
+			t.Error("Bad rate command should be an error:", line)
+		}
+	}
+}
+
+//impedance control should settle on its target in free space and press a surface with the spring's force
+func TestImpedance(t *testing.T) {
+	for _, wall := range []bool{false, true} {
+		arm := makeArm2().withAngles(ToRadians(30), ToRadians(70))
+		goal := ArmState{ToRadians(20), 0, ToRadians(40), 0}
+		target := arm.calcEndPoint(goal[0], goal[2])
+		if wall { //just short of the target
+			arm.surface = newContactSurface(Point{target.x - 0.1, 0}, Point{-1, 0}, 2000, 50)
+		}
+		arm.update()
+
+		ctrl := newImpedance(arm, []float64{400, 400}, []float64{60, 60})
+		ctrl.reset(arm.getState(), goal, 0)
+		for i := 0; i < 4*fps; i++ {
+			arm.arm1.voltage, arm.arm2.voltage = ctrl.calculate(arm.getState(), goal, float64(i)*dt)
+			arm.arm1.update()
+			arm.arm2.update()
+			arm.update()
+		} //loop
+
+		tip := arm.calcEndPoint(arm.arm1.angle, arm.arm2.angle)
+		if !wall {
+			if math.Hypot(tip.x-target.x, tip.y-target.y) > 0.01 {
+				t.Error("Impedance control ended at", tip.x, tip.y, "instead of", target.x, target.y)
+			}
+			continue
+		}
+
+		//the controller's spring and the surface act in series over the 0.1m
+		want := -0.1 * 400 * 2000 / (400 + 2000)
+		force := arm.calcExternalForce()
+		t.Log("Contact force:", force.x, force.y)
+		if math.Abs(force.x-want) > 2 || math.Abs(tip.y-target.y) > 0.01 {
+			t.Error("Pressed the surface with", force.x, "N at", tip.x, tip.y)
+		}
+	} //loop
+}
+
+//each arrival rule should be reported once when met, and dwell only after the others have held long enough
+func TestArrival(t *testing.T) {
+	arm := makeArm2().withAngles(ToRadians(40), ToRadians(20))
+	goal := ArmState{ToRadians(40.5), 0, ToRadians(20), 0}
+
+	criteria := arrivalCriteria{jointTolerance: ToRadians(1), radius: 0.02, velocity: 0.1, dwell: 0.1}
+	tracker := newArrivalTracker(criteria)
+
+	arrived, met := tracker.update(arm, goal, 0)
+	if arrived || len(met) != 3 {
+		t.Error("Should meet all but dwell straight away, met", met)
+	}
+	arrived, met = tracker.update(arm, goal, 0.06)
+	if arrived || len(met) != 0 {
+		t.Error("Should not arrive before dwelling, met", met)
+	}
+	arrived, met = tracker.update(arm, goal, 0.12)
+	if !arrived || len(met) != 1 || met[0] != dwellRule {
+		t.Error("Should arrive after dwelling, met", met)
+	}
+	if tracker.metAt[velocityRule] != 0 || tracker.metAt[dwellRule] != 0.12 {
+		t.Error("Wrong times the rules were met:", tracker.metAt)
+	}
+
+	//moving quickly stops it arriving but the rules stay reported
+	arm.arm1.vel = arm.arm1.maxVel
+	if arrived, met = tracker.update(arm, goal, 0.14); arrived || len(met) != 0 {
+		t.Error("Should not arrive while moving quickly")
+	}
+
+	//a tight radius alone is not met half a degree off
+	tracker = newArrivalTracker(arrivalCriteria{radius: 0.005})
+	if arrived, _ = tracker.update(arm, goal, 0); arrived {
+		t.Error("End point should be outside the radius")
+	}
+}
+
+//the error integrals of a known response and the metrics of a move through the state machine
+func TestMoveMetrics(t *testing.T) {
+	//a constant error of one radian for a second
+	r := stepResponse{start: 0, target: 1, motors: 2}
+	for i := 0; i <= 100; i++ {
+		r.record(float64(i)*0.01, 0, 2, 3)
+	}
+	m := calcStepMetrics(r)
+	if math.Abs(m.iae-1) > 1e-9 || math.Abs(m.ise-1) > 1e-9 || math.Abs(m.itae-0.5) > 0.01 || m.ssError != 1 {
+		t.Error("Wrong error integrals:", m.iae, m.ise, m.itae, m.ssError)
+	}
+	if m.peakVoltage != 2 || m.peakCurrent != 3 || math.Abs(m.energy-12) > 1e-9 {
+		t.Error("Wrong peaks or energy:", m.peakVoltage, m.peakCurrent, m.energy)
+	}
+
+	//a move from the state machine is analyzed once it finishes
+	loop := newArmLoop(makeArm2())
+	loop.setGoal(Point{0.6, 1.2})
+	for i := 0; i < 10*fps && !loop.arm2.isStopped(); i++ {
+		loop.onLoop()
+	} //loop
+	loop.setState(finished)
+	loop.onLoop()
+
+	if len(loop.metrics) != 1 {
+		t.Fatal("Move was not analyzed")
+	}
+	move := loop.metrics[0]
+	t.Log(move)
+	straight := math.Hypot(0.6-1.8, 1.2)
+	if move.pathLength < straight || move.maxDeviation <= 0 {
+		t.Error("Path should be at least the straight line, got", move.pathLength, move.maxDeviation)
+	}
+	for _, j := range move.joints {
+		if math.Abs(j.ssError) > ToRadians(1) || j.peakVoltage > MaxVoltage {
+			t.Error("Joint did not settle within the limits:", j)
+		}
+	}
+}
+
+//stepped sines and a chirp should measure the same loop, which follows slow setpoints and rolls off fast ones
+func TestFrequencyResponse(t *testing.T) {
+	setpoint := ArmState{ToRadians(30), 0, ToRadians(30), 0}
+	freqs := logFrequencies(0.1, 10, 12)
+
+	stepped := analyzeResponse(steppedSineResponse(1, setpoint, 1, freqs))
+	chirp := analyzeResponse(chirpResponse(1, setpoint, 1, freqs, 60))
+	t.Log("stepped:", stepped)
+	t.Log("chirp:", chirp)
+
+	if math.Abs(toDecibels(stepped.points[0].closed)) > 0.5 {
+		t.Error("Closed loop should follow slow setpoints, gain", toDecibels(stepped.points[0].closed))
+	}
+	if math.IsNaN(stepped.bandwidth) || math.Abs(chirp.bandwidth-stepped.bandwidth) > 0.1*stepped.bandwidth {
+		t.Error("Bandwidths do not agree:", stepped.bandwidth, chirp.bandwidth)
+	}
+	if stepped.phaseMargin <= 0 || math.Abs(chirp.phaseMargin-stepped.phaseMargin) > 5 {
+		t.Error("Phase margins do not agree:", stepped.phaseMargin, chirp.phaseMargin)
+	}
+
+	var b strings.Builder
+	writeBode(&b, stepped)
+	if lines := strings.Split(strings.TrimSpace(b.String()), "\n"); len(lines) != len(freqs)+1 || lines[0] != bodeHeader {
+		t.Error("CSV should have a header and a line per frequency")
+	}
+}
+
+//every IK solution should reach the point and each policy should pick the solution it describes
+func TestIKPolicies(t *testing.T) {
+	arm := makeArm2()
+	for _, p := range []Point{{0.5, 1.2}, {-0.9, 0.6}, {1.2, -0.3}} {
+		solutions := InverseKinematicsAll(p, 1.0, 0.8)
+		if len(solutions) != 2 {
+			t.Fatal("Should have two solutions for", p)
+		}
+		for _, s := range solutions {
+			if !WithinBounds(p, forwardKinematics(1.0, 0.8, s.q1, s.q2), 1e-9) {
+				t.Error("Solution does not reach", p)
+			}
+		}
+	}
+
+	//moving across x=0 should keep the elbow bent the same way
+	arm.arm1.angle, arm.arm2.angle = ToRadians(20), ToRadians(-60)
+	s, _ := arm.solveIK(Point{-0.4, 1.2}, closestIK)
+	if s.q2 > 0 {
+		t.Error("Closest solution flipped the elbow:", ToDegrees(s.q1), ToDegrees(s.q2))
+	}
+
+	up, _ := arm.solveIK(Point{-0.4, 1.2}, elbowUpIK)
+	down, _ := arm.solveIK(Point{-0.4, 1.2}, elbowDownIK)
+	if math.Sin(up.q1) <= math.Sin(down.q1) {
+		t.Error("Elbow up should be above elbow down")
+	}
+
+	//limits that rule out the bent-back elbow, whatever the policy
+	arm.arm2.setLimits(0, math.Pi)
+	for p := closestIK; p <= minTravelIK; p++ {
+		if s, err := arm.solveIK(Point{-0.4, 1.2}, p); err != nil || s.q2 < 0 {
+			t.Error(p, "policy picked", ToDegrees(s.q2), "outside the limits", err)
+		}
+	}
+
+	var ikErr *ikError
+	if _, err := arm.solveIK(Point{3, 0}, closestIK); !errors.As(err, &ikErr) || ikErr.reach != tooFar {
+		t.Error("Point out of reach should be an error, got", err)
+	}
+}
+
+//goals out of reach or past the limits should be reported with why and the nearest point, and stop the state machine unless it falls back
+func TestReachability(t *testing.T) {
+	cases := []struct {
+		p     Point
+		reach reachability
+	}{{Point{2, 1}, tooFar}, {Point{0.1, 0.1}, tooClose}, {Point{1.8, 0}, singular}, {Point{0.2, 0}, singular}, {Point{1, 1}, reachable}}
+	for _, c := range cases {
+		if r := checkReach(c.p, 1.0, 0.8); r != c.reach {
+			t.Error(c.p, "should be", c.reach, "but is", r)
+		}
+	}
+
+	arm := makeArm2()
+	_, err := arm.solveIK(Point{0, 0.05}, closestIK)
+	ikErr, ok := err.(*ikError)
+	if !ok || ikErr.reach != tooClose || checkReach(ikErr.nearest, 1.0, 0.8) != reachable ||
+		!WithinBounds(ikErr.nearest, arm.calcEndPoint(ikErr.fallback.q1, ikErr.fallback.q2), 1e-9) {
+		t.Error("Should fall back to a reachable point, got", err)
+	}
+
+	for _, fallback := range []bool{false, true} {
+		loop := newArmLoop(makeArm2())
+		loop.ikFallback = fallback
+		loop.setGoal(Point{3, 0.5})
+		for i := 0; i < 5*fps && !loop.arm2.isStopped() && loop.state != unreachable; i++ {
+			loop.onLoop()
+		} //loop
+
+		x := loop.arm2.getState()
+		if math.IsNaN(x[0]) || math.IsNaN(x[2]) {
+			t.Fatal("Arm state was corrupted")
+		}
+		if !fallback && (loop.state != unreachable || loop.err == nil) {
+			t.Error("Should be in the unreachable state, is", loop.state)
+		}
+		tip := loop.arm2.calcEndPoint(x[0], x[2])
+		if fallback && !WithinBounds(nearestReachable(Point{3, 0.5}, 1.0, 0.8), tip, 0.05) {
+			t.Error("Should have moved to the nearest point, is at", tip)
+		}
+	} //loop
+
+	//a goal only reached past the limits stops the arm with the default policy
+	loop := newArmLoop(makeArm2())
+	loop.arm2.arm2.setLimits(ToRadians(-10), ToRadians(10))
+	loop.setGoal(Point{0.8, 1.0})
+	loop.onLoop()
+	if ikErr, ok := loop.err.(*ikError); loop.state != unreachable || !ok || ikErr.reach != outsideLimits {
+		t.Error("Should be unreachable past the limits, is", loop.state, loop.err)
+	}
+}
+
+//the chain's Jacobian should match finite differences and flag the arm stretched out or folded
+func TestConditioning(t *testing.T) {
+	c := planarChain{[]float64{1.0, 0.8, 0.5}, []float64{0.3, 0.7, -0.4}}
+	J := c.jacobian()
+	for i := range c.angles {
+		moved := planarChain{c.lengths, append([]float64{}, c.angles...)}
+		moved.angles[i] += 1e-6
+		p0, p1 := c.endPoint(), moved.endPoint()
+		if math.Abs((p1.x-p0.x)/1e-6-J.at(0, i)) > 1e-4 || math.Abs((p1.y-p0.y)/1e-6-J.at(1, i)) > 1e-4 || J.at(2, i) != 1 {
+			t.Error("Jacobian column", i, "does not match the finite difference")
+		}
+	}
+
+	//the two-jointed arm's manipulability is l1 l2 |sin q2|
+	arm := makeArm2()
+	cond := arm.calcConditioning(0.4, ToRadians(90))
+	if math.Abs(cond.manipulability-0.8) > 1e-9 || cond.near != notSingular || math.IsInf(cond.condition, 0) {
+		t.Error("Wrong conditioning at a right angle:", cond.manipulability, cond.condition, cond.near)
+	}
+	if cond = arm.calcConditioning(0.4, ToRadians(1)); cond.near != extended || cond.condition < 50 {
+		t.Error("Should be near extended:", cond.near, cond.condition)
+	}
+	if cond = arm.calcConditioning(0.4, ToRadians(179)); cond.near != folded {
+		t.Error("Should be near folded:", cond.near)
+	}
+}
+
+//the workspace should keep the arm within its limits and out of the floor
+func TestWorkspace(t *testing.T) {
+	arm := makeArm2()
+	arm.arm1.setLimits(ToRadians(-30), ToRadians(210))
+	ws := newWorkspace(arm, []obstacle{floorObstacle{0}, circleObstacle{Point{1.2, 0.6}, 0.2}}, ToRadians(1), 0.05)
+	if len(ws.cells) == 0 {
+		t.Fatal("Workspace should not be empty")
+	}
+	for _, tip := range ws.cells {
+		if tip.y < -1e-9 || math.Hypot(tip.x-1.2, tip.y-0.6) < 0.2 {
+			t.Error("Workspace reaches into an obstacle at", tip)
+		}
+	}
+
+	//the arm lying on the floor is allowed, reaching through it or the circle is not
+	if !arm.isValidPose(0, 0, ws.obstacles) || arm.isValidPose(ToRadians(-10), 0, ws.obstacles) {
+		t.Error("Floor should only block poses below it")
+	}
+	if arm.isValidPose(ToRadians(30), 0, ws.obstacles) {
+		t.Error("Arm should hit the circle")
+	}
+
+	//below the floor clamps to somewhere the arm can actually reach
+	p := ws.clamp(Point{1, -0.5})
+	if p.y < 0 || !ws.reaches(p) {
+		t.Error("Clamped point should be reachable above the floor, is", p)
+	}
+	if p = ws.clamp(Point{-0.6, 0.9}); !WithinBounds(p, Point{-0.6, 0.9}, 1e-9) {
+		t.Error("Reachable point should not move, moved to", p)
+	}
+
+	//IK picks the other elbow when the closest one is in an obstacle, the one the workspace counted
+	arm = makeArm2()
+	arm.arm1.angle, arm.arm2.angle = ToRadians(60), ToRadians(-60)
+	arm.obstacles = []obstacle{circleObstacle{Point{0.5, 0.866}, 0.15}}
+	p = arm.calcEndPoint(arm.arm1.angle, arm.arm2.angle)
+	s, err := arm.solveIK(p, closestIK)
+	if err != nil || arm.collides(s.q1, s.q2, arm.obstacles) || !WithinBounds(arm.calcEndPoint(s.q1, s.q2), p, 1e-6) {
+		t.Error("IK should reach the point with the elbow clear of the obstacle:", ToDegrees(s.q1), ToDegrees(s.q2), err)
+	}
+	if !newWorkspace(arm, arm.obstacles, ToRadians(1), 0.05).reaches(p) {
+		t.Error("Workspace should count the point IK reaches")
+	}
+}
+
+//the numeric solver should reach points and headings with any chain, within its joint limits
+func TestNumericIK(t *testing.T) {
+	arm := makeArm2()
+	var solvers = []ikSolver{analyticSolver{arm2: arm}, arm.numericSolver()}
+	for _, s := range solvers {
+		res, err := s.solve(pointGoal(Point{-0.4, 1.2}), []float64{0.5, 0.5})
+		tip := arm.calcEndPoint(res.angles[0], res.angles[1])
+		if err != nil || !res.converged || !WithinBounds(tip, Point{-0.4, 1.2}, 1e-5) {
+			t.Errorf("%T should reach the point, got %v after %d iterations: %v", s, tip, res.iterations, err)
+		}
+	}
+	for _, s := range solvers {
+		res, err := s.solve(orientedGoal(Point{1, 1}, 0), []float64{0.5, 0.5})
+		if err != errHeadingTwoLinks || isUnreachable(err) || res.residual > 1e-5 {
+			t.Errorf("%T on two links should reach the point but not take a heading, got %v %.6f", s, err, res.residual)
+		}
+	}
+
+	//three links reach a point with the last link pointing straight down
+	s := newNumericSolver([]float64{1.0, 0.8, 0.4})
+	target := orientedGoal(Point{0.9, 0.6}, -math.Pi/2)
+	res, err := s.solve(target, []float64{0.3, 0.3, 0.3})
+	c := planarChain{s.lengths, res.angles}
+	heading := res.angles[0] + res.angles[1] + res.angles[2]
+	if err != nil || !WithinBounds(c.endPoint(), target.point, 1e-5) || math.Abs(math.Remainder(heading+math.Pi/2, 2*math.Pi)) > 1e-5 {
+		t.Error("Should reach the point and heading, got", c.endPoint(), ToDegrees(heading), err)
+	}
+
+	//limits are kept even when they stop it reaching the point
+	s.setLimits(1, 0, ToRadians(30))
+	res, _ = s.solve(pointGoal(Point{0.9, 0.6}), []float64{0.3, 0.3, 0.3})
+	if res.angles[1] < 0 || res.angles[1] > ToRadians(30)+1e-9 {
+		t.Error("Second joint past its limits at", ToDegrees(res.angles[1]))
+	}
+
+	//out of reach gets as close as it can and says it didn't converge
+	var convErr *convergenceError
+	res, err = s.solve(pointGoal(Point{3, 0}), []float64{0.3, 0.3, 0.3})
+	if !errors.As(err, &convErr) || res.converged || math.Abs(res.residual-0.8) > 1e-3 {
+		t.Error("Should stop 0.8m short of an unreachable point, is", res.residual, err)
+	}
+
+	//the state machine stops for a goal the numeric solver can't reach like for any other
+	loop := newArmLoop(makeArm2())
+	loop.solver = loop.arm2.numericSolver()
+	loop.setGoal(Point{3, 0.5})
+	loop.onLoop()
+	if loop.state != unreachable || loop.err == nil {
+		t.Error("Should be in the unreachable state, is", loop.state)
+	}
+}
+
+//the wrist should hold the claw at the goal's heading, give up the heading or the point when it can't have both, and stop when it has neither
+func TestWrist(t *testing.T) {
+	arm := makeArm2()
+	arm.addWrist(0.25, 2)
+
+	//a level claw reaching out in front
+	goal := orientedGoal(Point{1.2, 0.6}, 0)
+	s, q3, err := arm.solveWristIK(goal, closestIK, keepHeading)
+	if err != nil || !WithinBounds(arm.calcClawPoint(s.q1, s.q2, q3), goal.point, 1e-9) || math.Abs(math.Remainder(s.q1+s.q2+q3, 2*math.Pi)) > 1e-9 {
+		t.Error("Should reach the goal with a level claw, got", arm.calcClawPoint(s.q1, s.q2, q3), ToDegrees(s.q1+s.q2+q3), err)
+	}
+
+	//pointing back at the base from the edge of the reach puts the wrist out of reach
+	goal = orientedGoal(Point{1.9, 0}, math.Pi)
+	var oErr *orientationError
+	s, q3, err = arm.solveWristIK(goal, closestIK, keepHeading)
+	if !errors.As(err, &oErr) || math.Abs(math.Remainder(oErr.reached.heading-math.Pi, 2*math.Pi)) > 1e-9 ||
+		!WithinBounds(arm.calcClawPoint(s.q1, s.q2, q3), oErr.reached.point, 1e-9) {
+		t.Error("Should keep the heading and get as close as it can, got", err)
+	}
+	s, q3, err = arm.solveWristIK(goal, closestIK, keepPoint)
+	if !errors.As(err, &oErr) || !WithinBounds(arm.calcClawPoint(s.q1, s.q2, q3), goal.point, 1e-9) ||
+		math.Abs(math.Remainder(s.q1+s.q2+q3-math.Pi, 2*math.Pi)) < ToRadians(10) {
+		t.Error("Should keep the point at another heading, got", err)
+	}
+
+	//out of reach at every heading is out of reach, not a heading to give up
+	var ikErr *ikError
+	for _, fallback := range []orientFallback{keepHeading, keepPoint} {
+		if _, _, err = arm.solveWristIK(pointGoal(Point{5, 0.5}), closestIK, fallback); !errors.As(err, &ikErr) || ikErr.reach != tooFar {
+			t.Error("Goal out of reach should be too far with the", fallback, "fallback, got", err)
+		}
+	}
+	farArm := makeArm2()
+	farArm.addWrist(0.25, 2)
+	far := newArmLoop(farArm)
+	far.setGoal(Point{5, 0.5})
+	far.onLoop()
+	if far.state != unreachable || far.err == nil {
+		t.Error("Should stop for a goal out of reach, is", far.state)
+	}
+
+	//the state machine drives the wrist along with the rest of the arm
+	loop := newArmLoop(arm)
+	loop.setGoalWithArrival(orientedGoal(Point{1.2, 0.6}, 0), defaultArrival)
+	for i := 0; i < 10*fps && !loop.arm2.isStopped(); i++ {
+		loop.onLoop()
+	} //loop
+	loop.setState(finished)
+	loop.onLoop()
+
+	claw := arm.calcClawPoint(arm.arm1.angle, arm.arm2.angle, arm.arm3.angle)
+	if !WithinBounds(claw, Point{1.2, 0.6}, 0.03) || math.Abs(ToDegrees(arm.calcHeading())) > 2 {
+		t.Error("Claw should be level at the goal, is at", claw, ToDegrees(arm.calcHeading()))
+	}
+}
+
+//the planner should find a path around an obstacle the straight move hits, and the arm should follow it
+func TestPlanner(t *testing.T) {
+	arm := makeArm2()
+	obstacles := []obstacle{floorObstacle{0}, circleObstacle{Point{0, 1.5}, 0.2}}
+	p := newPlanner(arm, obstacles, 1)
+
+	start, goal := ikSolution{ToRadians(10), 0}, ikSolution{ToRadians(150), ToRadians(30)}
+	if p.edgeValid(start, goal) {
+		t.Fatal("Straight move should hit the obstacle")
+	}
+	path, err := p.plan(start, goal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path[0] != start || path[len(path)-1] != goal {
+		t.Error("Path should go from the start to the goal, is", path)
+	}
+	for i := 1; i < len(path); i++ {
+		if !p.edgeValid(path[i-1], path[i]) {
+			t.Error("Path hits an obstacle between", path[i-1], path[i])
+		}
+	}
+
+	//a goal inside an obstacle can't be planned to
+	if _, err := p.plan(start, ikSolution{ToRadians(90), 0}); err == nil {
+		t.Error("Should not plan into an obstacle")
+	}
+
+	//the trajectory follower goes around, clearing a slightly smaller obstacle despite tracking error
+	arm.arm1.angle = start.q1
+	arm.update()
+	loop := newArmLoop(arm)
+	loop.planner = p
+	loop.setController("pid-id")
+	loop.setGoal(arm.calcEndPoint(goal.q1, goal.q2))
+	inner := []obstacle{circleObstacle{Point{0, 1.5}, 0.15}}
+	for i := 0; i < 20*fps && !loop.arm2.isStopped(); i++ {
+		loop.onLoop()
+		if arm.collides(arm.arm1.angle, arm.arm2.angle, inner) {
+			t.Fatal("Arm went through the obstacle at", ToDegrees(arm.arm1.angle), ToDegrees(arm.arm2.angle))
+		}
+	} //loop
+	loop.setState(finished)
+	loop.onLoop()
+
+	tip := arm.calcEndPoint(arm.arm1.angle, arm.arm2.angle)
+	if len(loop.path) < 3 || !WithinBounds(tip, arm.calcEndPoint(goal.q1, goal.q2), 0.02) {
+		t.Error("Should have followed a path to the goal, is at", tip, "after", loop.path)
+	}
+
+	//only pid-id follows the planned paths, and other controllers are refused
+	for _, c := range []struct{ ctrl, ff string }{{"pid", "gravity"}, {"pid-id", "gravity"}, {"pid", "id"}} {
+		if got, err := chooseController(c.ctrl, c.ff, true); got != "pid-id" || err != nil {
+			t.Error("Planning with", c.ctrl, "should use pid-id, not", got, err)
+		}
+	}
+	if _, err := chooseController("lqr", "gravity", true); err == nil {
+		t.Error("Planning with lqr should be an error")
+	}
+	if got, err := chooseController("lqr", "id", false); got != "lqr" || err != nil {
+		t.Error("Without planning lqr should be kept, not", got, err)
+	}
+}
+
+//splines should pass through every waypoint with continuous motion, and the arm should follow them without stopping
+func TestSpline(t *testing.T) {
+	arm := makeArm2()
+	path := jointPath{{0, 0}, {0.6, 0.4}, {1.2, 0.2}, {1.4, -0.3}}
+	durations := []float64{1, 1.5, 0.8}
+
+	for _, kind := range []splineKind{cubicSpline, quinticSpline} {
+		traj := fitSpline(path, durations, splineOptions{kind: kind, velocity: 1})
+		if math.Abs(traj.duration()-3.3) > 1e-9 {
+			t.Error("Wrong duration", traj.duration())
+		}
+		knotTime := 0.0
+		for i, q := range path {
+			sp1, sp2 := traj.sample(knotTime)
+			if math.Abs(sp1.pos-q.q1) > 1e-9 || math.Abs(sp2.pos-q.q2) > 1e-9 {
+				t.Error(kind, "should pass through waypoint", i, "got", sp1.pos, sp2.pos)
+			}
+			if i > 0 && i < len(path)-1 {
+				//continuous either side of the waypoint, and moving through it
+				b1, _ := traj.sample(knotTime - 1e-7)
+				a1, _ := traj.sample(knotTime + 1e-7)
+				if math.Abs(a1.vel-b1.vel) > 1e-4 || sp1.vel == 0 {
+					t.Error(kind, "velocity jumps or stops at waypoint", i, b1.vel, a1.vel)
+				}
+				if kind == quinticSpline && math.Abs(a1.acc-b1.acc) > 1e-3 {
+					t.Error("Quintic acceleration jumps at waypoint", i, b1.acc, a1.acc)
+				}
+			}
+			if i < len(durations) {
+				knotTime += durations[i]
+			}
+		}
+		end1, _ := traj.sample(traj.duration())
+		if math.Abs(end1.vel) > 1e-9 || (kind == quinticSpline && math.Abs(end1.acc) > 1e-6) {
+			t.Error(kind, "should end at rest")
+		}
+	}
+
+	//coming to rest at each point is a minimum jerk move
+	rest := arm.newPathTrajectory(jointPath{{0, 0}, {1, -0.5}})
+	for _, s := range []float64{0.1, 0.4, 0.7} {
+		sp, _ := rest.sample(s * rest.duration())
+		if minJerk := 10*math.Pow(s, 3) - 15*math.Pow(s, 4) + 6*math.Pow(s, 5); math.Abs(sp.pos-minJerk) > 1e-9 {
+			t.Error("Path trajectory should be a minimum jerk move, at", sp.pos, "instead of", minJerk)
+		}
+	}
+
+	//the Cartesian spline puts the end point on each waypoint
+	cart := arm.newCartesianTrajectory(jointPath{{0.3, 0.5}, {0.8, 0.6}, {1.3, 0.4}}, splineOptions{kind: quinticSpline, space: cartesianSpace, velocity: 1})
+	times := arm.calcSegmentTimes(jointPath{{0.3, 0.5}, {0.8, 0.6}, {1.3, 0.4}})
+	sp1, sp2 := cart.sample(times[0])
+	if !WithinBounds(arm.calcEndPoint(sp1.pos, sp2.pos), arm.calcEndPoint(0.8, 0.6), 1e-6) {
+		t.Error("Cartesian spline should pass through the waypoint, is at", arm.calcEndPoint(sp1.pos, sp2.pos))
+	}
+
+	//the arm passes every waypoint and arrives at the last
+	for _, space := range []splineSpace{jointSpace, cartesianSpace} {
+		loop := newArmLoop(makeArm2())
+		loop.spline = splineOptions{kind: quinticSpline, space: space, velocity: 1}
+		loop.setController("pid-id")
+		goals := []Goal{pointGoal(Point{1.4, 0.6}), pointGoal(Point{0.8, 1.2}), pointGoal(Point{-0.2, 1.4})}
+		loop.setWaypoints(goals, defaultArrival)
+		closest := []float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+		for i := 0; i < 20*fps && !loop.arm2.isStopped(); i++ {
+			loop.onLoop()
+			tip := loop.arm2.calcEndPoint(loop.arm2.arm1.angle, loop.arm2.arm2.angle)
+			for j, g := range goals {
+				closest[j] = math.Min(closest[j], math.Hypot(tip.x-g.point.x, tip.y-g.point.y))
+			}
+		} //loop
+		loop.setState(finished)
+		loop.onLoop()
+		for j, d := range closest {
+			if d > 0.05 {
+				t.Error(space, "spline missed waypoint", j, "by", d)
+			}
+		}
+	}
+
+	//the claw rather than the end of the elbow passes through the waypoints, holding its heading
+	clawArm := makeArm2()
+	clawArm.addWrist(0.25, 2)
+	loop := newArmLoop(clawArm)
+	loop.spline = splineOptions{kind: cubicSpline, space: jointSpace, velocity: 1}
+	loop.setController("pid-id")
+	goals := []Goal{pointGoal(Point{1.4, 0.6}), pointGoal(Point{0.8, 1.2}), pointGoal(Point{-0.2, 1.4})}
+	loop.setWaypoints(goals, defaultArrival)
+	closest := []float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	for i := 0; i < 20*fps && !loop.arm2.isStopped(); i++ {
+		loop.onLoop()
+		claw := clawArm.calcClawPoint(clawArm.arm1.angle, clawArm.arm2.angle, clawArm.arm3.angle)
+		for j, g := range goals {
+			closest[j] = math.Min(closest[j], math.Hypot(claw.x-g.point.x, claw.y-g.point.y))
+		}
+	} //loop
+	loop.setState(finished)
+	loop.onLoop()
+	for j, d := range closest {
+		if d > 0.05 {
+			t.Error("Claw missed waypoint", j, "by", d)
+		}
+	}
+
+	//only pid-id passes through them
+	if loop.setController("pid"); loop.followsTrajectories() {
+		t.Error("pid should not follow the spline")
+	}
+}
+
+//the time-optimal timing should stay within the motor limits, pushing one of them the whole way, and beat the fixed timing
+func TestTimeOptimal(t *testing.T) {
+	arm := makeArm2()
+	path := jointPath{{0, 0}, {1.2, -0.6}}
+	limits := toppLimits{voltage: 10}
+
+	traj, err := arm.timeOptimal(path, limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start1, start2 := traj.sample(0)
+	end1, end2 := traj.sample(traj.duration())
+	if start1.pos != 0 || start2.pos != 0 || math.Abs(end1.pos-1.2) > 1e-9 || math.Abs(end2.pos+0.6) > 1e-9 || end1.vel != 0 {
+		t.Error("Should go from the start to the end of the path, at rest at the end")
+	}
+
+	saturated := 0
+	for tm := 0.0; tm < traj.duration(); tm += traj.duration() / 100 {
+		sp1, sp2 := traj.sample(tm)
+		v1, v2 := calcIDFFArm2(arm, sp1, sp2)
+		if math.Abs(v1) > 10.1 || math.Abs(v2) > 10.1 {
+			t.Fatal("Voltage past the limit at", tm, v1, v2)
+		}
+		if math.Max(math.Abs(v1), math.Abs(v2)) > 9.5 {
+			saturated++
+		}
+	}
+	fixed := arm.newPathTrajectory(path).duration()
+	t.Log("time-optimal", traj.duration(), "fixed", fixed)
+	if saturated < 80 || traj.duration() >= fixed {
+		t.Error("Should push a limit most of the way and beat the fixed timing:", saturated, traj.duration(), fixed)
+	}
+
+	//tighter limits take longer, and the current limit holds
+	slow, err := arm.timeOptimal(path, toppLimits{voltage: 10, current: 80})
+	if err != nil || slow.duration() <= traj.duration() {
+		t.Fatal("Current limit should slow the move down", err)
+	}
+	for tm := 0.0; tm < slow.duration(); tm += slow.duration() / 100 {
+		sp1, sp2 := slow.sample(tm)
+		v1, v2 := calcIDFFArm2(arm, sp1, sp2)
+		if math.Abs(arm.arm1.calcCurrent(v1, sp1.vel)) > 81 || math.Abs(arm.arm2.calcCurrent(v2, sp2.vel)) > 81 {
+			t.Fatal("Current past the limit at", tm)
+		}
+	}
+
+	//too weak to hold the arm out straight
+	if _, err := arm.timeOptimal(path, toppLimits{voltage: 0.1}); err != errPathInfeasible {
+		t.Error("Should not be able to hold the arm, got", err)
+	}
+
+	//the arm follows the time-optimal move to the goal faster than the fixed timing
+	var steps [2]int
+	for i, topp := range []*toppLimits{nil, &limits} {
+		loop := newArmLoop(makeArm2())
+		loop.topp = topp
+		loop.setController("pid-id")
+		loop.setGoal(arm.calcEndPoint(1.2, -0.6))
+		for ; steps[i] < 10*fps && !loop.arm2.isStopped(); steps[i]++ {
+			loop.onLoop()
+		} //loop
+		loop.setState(finished)
+		loop.onLoop()
+		if tip := loop.arm2.calcEndPoint(loop.arm2.arm1.angle, loop.arm2.arm2.angle); !WithinBounds(tip, arm.calcEndPoint(1.2, -0.6), 0.05) {
+			t.Error("Should have arrived at the goal, is at", tip)
+		}
+	} //loop
+	if steps[1] >= steps[0] {
+		t.Error("Time-optimal move should arrive sooner:", steps)
+	}
+}
+
+//queued goals should be reordered into the fastest tour, exactly when there are few and close to it when there are many
+func TestOrder(t *testing.T) {
+	//the exact order matches trying every order, with and without fixed ends
+	rng := rand.New(rand.NewSource(3))
+	n := 6
+	times := make([][]float64, n+1)
+	for i := range times {
+		times[i] = make([]float64, n+1)
+		for j := 1; j <= n; j++ {
+			if i != j {
+				times[i][j] = 0.1 + rng.Float64()
+			}
+		}
+	}
+	var perms [][]int
+	var permute func(order []int, k int)
+	permute = func(order []int, k int) {
+		if k == len(order) {
+			perms = append(perms, append([]int{}, order...))
+			return
+		}
+		for i := k; i < len(order); i++ {
+			order[k], order[i] = order[i], order[k]
+			permute(order, k+1)
+			order[k], order[i] = order[i], order[k]
+		}
+	}
+	permute([]int{0, 1, 2, 3, 4, 5}, 0)
+	for f := fixNone; f <= fixBoth; f++ {
+		best := math.Inf(1)
+		for _, p := range perms {
+			if (f == fixFirst || f == fixBoth) && p[0] != 0 || (f == fixLast || f == fixBoth) && p[n-1] != n-1 {
+				continue
+			}
+			best = math.Min(best, calcTourTime(times, p))
+		}
+		order := orderTour(times, f)
+		if len(order) != n || math.Abs(calcTourTime(times, order)-best) > 1e-9 {
+			t.Error("Fixing", f, "should find the best order", best, "got", order, calcTourTime(times, order))
+		}
+		if (f == fixFirst || f == fixBoth) && order[0] != 0 || (f == fixLast || f == fixBoth) && order[n-1] != n-1 {
+			t.Error("Fixing", f, "should keep the ends in place, got", order)
+		}
+	}
+
+	//too many for the exact order, points along a line in a shuffled order
+	n = 14
+	spots := rng.Perm(n)
+	times = make([][]float64, n+1)
+	for i := range times {
+		times[i] = make([]float64, n+1)
+		for j := 1; j <= n; j++ {
+			at := -1.0
+			if i > 0 {
+				at = float64(spots[i-1])
+			}
+			times[i][j] = math.Abs(float64(spots[j-1]) - at)
+		}
+	}
+	order := orderTour(times, fixLast)
+	seen := map[int]bool{}
+	for _, i := range order {
+		seen[i] = true
+	}
+	given := make([]int, n)
+	for i := range given {
+		given[i] = i
+	}
+	if len(seen) != n || order[n-1] != n-1 || calcTourTime(times, order) > calcTourTime(times, given) {
+		t.Error("Should visit every point once, ending on the last, faster than the given order:", order)
+	}
+
+	//goals on alternating sides of the arm are grouped by side
+	arm := makeArm2()
+	loop := newArmLoop(arm)
+	var goals []Goal
+	for _, q1 := range []float64{0.3, 2.8, 0.5, 2.6} {
+		goals = append(goals, pointGoal(arm.calcEndPoint(q1, 0.3)))
+	}
+	ordered, best, clicked := loop.orderGoals(ikSolution{0, 0}, goals, fixNone)
+	t.Log("reordered", best, "clicked", clicked)
+	if best >= clicked || ordered[0] != goals[0] || ordered[1] != goals[2] {
+		t.Error("Should visit the near side first and be faster than the click order:", ordered, best, clicked)
+	}
+
+	//the time-optimal timing is faster still, and reorders the same way
+	loop.topp = &toppLimits{voltage: 10}
+	if fastest, fast, _ := loop.orderGoals(ikSolution{0, 0}, goals, fixNone); fast >= best || fastest[1] != goals[2] {
+		t.Error("Time-optimal moves should be predicted faster:", fastest, fast, best)
+	}
+
+	//adding a goal only times the moves to and from it
+	timed := len(loop.moveTimes)
+	goals = append(goals, pointGoal(arm.calcEndPoint(1.5, 0.3)))
+	loop.orderGoals(ikSolution{0, 0}, goals, fixNone)
+	if added := len(loop.moveTimes) - timed; added != 2*len(goals)-1 {
+		t.Error("Should only time the moves to and from the new goal, timed", added)
+	}
+}
@@ -0,0 +1,176 @@
+//matrix
+//Created on: 10/19/2026
+//A small dense matrix for the state-space math
+
+package main
+
+import (
+	"errors"
+	"math"
+)
+
+//matrix is a small dense matrix stored row by row
+type matrix struct {
+	rows int       //number of rows
+	cols int       //number of columns
+	data []float64 //values row by row
+} //end struct
+
+//Create a matrix filled with values
+//int rows - number of rows
+//int cols - number of columns
+//...float64 values - values row by row, zero if left out
+func newMatrix(rows, cols int, values ...float64) matrix {
+	m := matrix{rows, cols, make([]float64, rows*cols)}
+	copy(m.data, values)
+	return m
+} //end newMatrix
+
+//Create an identity matrix
+//int n - size of the matrix
+func identityMatrix(n int) matrix {
+	m := newMatrix(n, n)
+	for i := 0; i < n; i++ {
+		m.set(i, i, 1)
+	} //loop
+	return m
+} //end identityMatrix
+
+//Create a square matrix with values along the diagonal
+//...float64 values - diagonal values
+func diagonalMatrix(values ...float64) matrix {
+	m := newMatrix(len(values), len(values))
+	for i, v := range values {
+		m.set(i, i, v)
+	} //loop
+	return m
+} //end diagonalMatrix
+
+//Get a value in the matrix
+//int i - row
+//int j - column
+func (m matrix) at(i, j int) float64 {
+	return m.data[i*m.cols+j]
+} //end at
+
+//Set a value in the matrix
+//int i - row
+//int j - column
+//float64 v - new value
+func (m matrix) set(i, j int, v float64) {
+	m.data[i*m.cols+j] = v
+} //end set
+
+//Multiply the matrix by another
+//matrix n - matrix on the right
+//return - the product
+func (m matrix) mul(n matrix) matrix {
+	p := newMatrix(m.rows, n.cols)
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < n.cols; j++ {
+			sum := 0.0
+			for k := 0; k < m.cols; k++ {
+				sum += m.at(i, k) * n.at(k, j)
+			} //loop
+			p.set(i, j, sum)
+		} //loop
+	} //loop
+	return p
+} //end mul
+
+//Add another matrix to the matrix
+//matrix n - matrix of the same size
+func (m matrix) add(n matrix) matrix {
+	s := newMatrix(m.rows, m.cols)
+	for i := range m.data {
+		s.data[i] = m.data[i] + n.data[i]
+	} //loop
+	return s
+} //end add
+
+//Subtract another matrix from the matrix
+//matrix n - matrix of the same size
+func (m matrix) sub(n matrix) matrix {
+	return m.add(n.scale(-1))
+} //end sub
+
+//Scale every value in the matrix
+//float64 k - value to scale by
+func (m matrix) scale(k float64) matrix {
+	s := newMatrix(m.rows, m.cols)
+	for i := range m.data {
+		s.data[i] = m.data[i] * k
+	} //loop
+	return s
+} //end scale
+
+//Get the transpose of the matrix
+func (m matrix) transpose() matrix {
+	t := newMatrix(m.cols, m.rows)
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			t.set(j, i, m.at(i, j))
+		} //loop
+	} //loop
+	return t
+} //end transpose
+
+//Get the inverse of a square matrix using Gauss-Jordan elimination
+//return - the inverse, or an error if the matrix is singular
+func (m matrix) inverse() (matrix, error) {
+	n := m.rows
+	a := newMatrix(n, n, m.data...)
+	inv := identityMatrix(n)
+
+	for col := 0; col < n; col++ {
+		//pick the largest pivot to keep rounding errors down
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a.at(row, col)) > math.Abs(a.at(pivot, col)) {
+				pivot = row
+			} //if
+		} //loop
+		if math.Abs(a.at(pivot, col)) < 1e-12 {
+			return matrix{}, errors.New("matrix is singular")
+		} //if
+		a.swapRows(col, pivot)
+		inv.swapRows(col, pivot)
+
+		//normalize the pivot row and eliminate the column from the other rows
+		p := a.at(col, col)
+		for j := 0; j < n; j++ {
+			a.set(col, j, a.at(col, j)/p)
+			inv.set(col, j, inv.at(col, j)/p)
+		} //loop
+		for row := 0; row < n; row++ {
+			if row == col {
+				continue
+			} //if
+			f := a.at(row, col)
+			for j := 0; j < n; j++ {
+				a.set(row, j, a.at(row, j)-f*a.at(col, j))
+				inv.set(row, j, inv.at(row, j)-f*inv.at(col, j))
+			} //loop
+		} //loop
+	} //loop
+
+	return inv, nil
+} //end inverse
+
+//Swap two rows of the matrix
+//int i - first row
+//int j - second row
+func (m matrix) swapRows(i, j int) {
+	for k := 0; k < m.cols; k++ {
+		m.data[i*m.cols+k], m.data[j*m.cols+k] = m.data[j*m.cols+k], m.data[i*m.cols+k]
+	} //loop
+} //end swapRows
+
+//Get the largest absolute value in the matrix
+func (m matrix) maxAbs() float64 {
+	max := 0.0
+	for _, v := range m.data {
+		max = math.Max(max, math.Abs(v))
+	} //loop
+	return max
+} //end maxAbs
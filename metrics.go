@@ -0,0 +1,212 @@
+//metrics
+//Created on: 10/19/2026
+//Performance metrics calculated from the recorded response of a joint and the path of a move
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+//stepResponse is the recorded motion of a joint moving to a target
+type stepResponse struct {
+	times    []float64 //time of each sample in seconds
+	angles   []float64 //angle of the joint at each sample in radians
+	voltages []float64 //voltage applied to the joint at each sample
+	currents []float64 //current through each motor at each sample in Amps
+	start    float64   //angle the joint started at
+	target   float64   //angle the joint was moving to
+	motors   float64   //number of motors driving the joint, 1 if not set
+} //end struct
+
+//stepMetrics summarizes how well a joint moved to its target
+type stepMetrics struct {
+	riseTime   float64 //time to go from 10% to 90% of the step in seconds
+	settleTime float64 //time to stay within 2% of the step in seconds, NaN if it never settled
+	overshoot  float64 //how far past the target the joint went as a percent of the step
+	effort     float64 //integral of the squared voltage in V^2s
+
+	ssError     float64 //error left at the end of the response in radians
+	iae         float64 //integral of the absolute error in rad s
+	ise         float64 //integral of the squared error in rad^2 s
+	itae        float64 //integral of the time-weighted absolute error in rad s^2
+	peakVoltage float64 //largest voltage magnitude applied in Volts
+	peakCurrent float64 //largest current magnitude through each motor in Amps
+	energy      float64 //electrical energy through all the motors in Joules
+} //end struct
+
+//Record a sample of the response
+//float64 t - time in seconds
+//float64 angle - angle of the joint in radians
+//float64 voltage - voltage applied to the joint
+//float64 current - current through each motor in Amps
+func (r *stepResponse) record(t, angle, voltage, current float64) {
+	r.times = append(r.times, t)
+	r.angles = append(r.angles, angle)
+	r.voltages = append(r.voltages, voltage)
+	r.currents = append(r.currents, current)
+} //end record
+
+//Calculate the metrics of a step response
+//stepResponse r - recorded response
+//return - the metrics of the response
+func calcStepMetrics(r stepResponse) stepMetrics {
+	m := stepMetrics{riseTime: math.NaN(), settleTime: math.NaN()}
+	if len(r.times) == 0 {
+		return m
+	} //if
+	m.calcErrorIntegrals(r)
+
+	step := r.target - r.start
+	if step == 0 {
+		return m
+	} //if
+
+	rise10, rise90 := math.NaN(), math.NaN()
+	settled := -1 //index the response last entered the settling band
+	for i, angle := range r.angles {
+		progress := (angle - r.start) / step //fraction of the step completed
+
+		if math.IsNaN(rise10) && progress >= 0.1 {
+			rise10 = r.times[i]
+		} //if
+		if math.IsNaN(rise90) && progress >= 0.9 {
+			rise90 = r.times[i]
+		} //if
+		m.overshoot = math.Max(m.overshoot, (progress-1)*100)
+
+		if math.Abs(1-progress) <= 0.02 {
+			if settled < 0 {
+				settled = i
+			} //if
+		} else {
+			settled = -1
+		} //if
+
+		if i > 0 {
+			m.effort += r.voltages[i] * r.voltages[i] * (r.times[i] - r.times[i-1])
+		} //if
+	} //loop
+
+	m.riseTime = rise90 - rise10
+	if settled >= 0 {
+		m.settleTime = r.times[settled] - r.times[0]
+	} //if
+
+	return m
+} //end calcStepMetrics
+
+//Calculate the metrics that do not depend on the size of the step
+//stepResponse r - recorded response
+func (m *stepMetrics) calcErrorIntegrals(r stepResponse) {
+	motors := r.motors
+	if motors == 0 {
+		motors = 1
+	} //if
+
+	for i, angle := range r.angles {
+		e := math.Abs(r.target - angle)
+		m.peakVoltage = math.Max(m.peakVoltage, math.Abs(r.voltages[i]))
+		if i < len(r.currents) {
+			m.peakCurrent = math.Max(m.peakCurrent, math.Abs(r.currents[i]))
+		} //if
+
+		if i > 0 {
+			h := r.times[i] - r.times[i-1]
+			m.iae += e * h
+			m.ise += e * e * h
+			m.itae += (r.times[i] - r.times[0]) * e * h
+			if i < len(r.currents) { //energy in or out of the motors both count
+				m.energy += math.Abs(r.voltages[i]*r.currents[i]) * motors * h
+			} //if
+		} //if
+	} //loop
+
+	m.ssError = r.target - r.angles[len(r.angles)-1]
+} //end calcErrorIntegrals
+
+//Get a string representation of the metrics
+func (m stepMetrics) String() string {
+	return fmt.Sprintf("rise %.3f s, settle %.3f s, overshoot %.2f %%, ss error %.3f deg, effort %.1f V^2s\n"+
+		"IAE %.4f, ISE %.4f, ITAE %.4f, peak %.2f V, peak %.1f A, energy %.1f J",
+		m.riseTime, m.settleTime, m.overshoot, ToDegrees(m.ssError), m.effort,
+		m.iae, m.ise, m.itae, m.peakVoltage, m.peakCurrent, m.energy)
+} //end String
+
+//moveRecord is the recorded motion of the whole arm moving to a goal
+type moveRecord struct {
+	joints [2]stepResponse //response of each joint
+	path   []Point         //end point at each sample in meters
+	target Point           //end point the arm was moving to in meters
+} //end struct
+
+//moveMetrics summarizes how well the arm moved to a goal
+type moveMetrics struct {
+	joints       [2]stepMetrics //metrics of each joint
+	pathLength   float64        //distance the end point travelled in meters
+	maxDeviation float64        //furthest the end point strayed from the straight line to the target in meters
+} //end struct
+
+//Start recording a move
+//Arm2 a2 - arm about to move
+//ArmState goal - state the arm is moving to
+//return - empty record of the move
+func newMoveRecord(a2 Arm2, goal ArmState) moveRecord {
+	var r moveRecord
+	r.joints[0] = stepResponse{start: a2.arm1.angle, target: goal[0], motors: a2.arm1.numMotors}
+	r.joints[1] = stepResponse{start: a2.arm2.angle, target: goal[2], motors: a2.arm2.numMotors}
+	r.path = []Point{a2.calcEndPoint(a2.arm1.angle, a2.arm2.angle)}
+	r.target = a2.calcEndPoint(goal[0], goal[2])
+	return r
+} //end newMoveRecord
+
+//Record a sample of the move
+//Arm2 a2 - arm that is moving
+//float64 t - time in seconds
+func (r *moveRecord) record(a2 Arm2, t float64) {
+	r.joints[0].record(t, a2.arm1.angle, a2.arm1.voltage, a2.arm1.current)
+	r.joints[1].record(t, a2.arm2.angle, a2.arm2.voltage, a2.arm2.current)
+	r.path = append(r.path, a2.calcEndPoint(a2.arm1.angle, a2.arm2.angle))
+} //end record
+
+//Calculate the metrics of a move
+//moveRecord r - recorded move
+//return - the metrics of the move
+func calcMoveMetrics(r moveRecord) moveMetrics {
+	var m moveMetrics
+	m.joints[0] = calcStepMetrics(r.joints[0])
+	m.joints[1] = calcStepMetrics(r.joints[1])
+
+	for i, p := range r.path {
+		if i > 0 {
+			m.pathLength += math.Hypot(p.x-r.path[i-1].x, p.y-r.path[i-1].y)
+		} //if
+		m.maxDeviation = math.Max(m.maxDeviation, distanceToSegment(p, r.path[0], r.target))
+	} //loop
+
+	return m
+} //end calcMoveMetrics
+
+//Get a string representation of the metrics
+func (m moveMetrics) String() string {
+	return fmt.Sprintf("joint 1: %v\njoint 2: %v\npath %.3f m, max deviation %.3f m",
+		m.joints[0], m.joints[1], m.pathLength, m.maxDeviation)
+} //end String
+
+//Calculate the distance from a point to a line segment
+//Point p - point to measure from
+//Point a - start of the segment
+//Point b - end of the segment
+//return - shortest distance from the point to the segment
+func distanceToSegment(p, a, b Point) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	length2 := dx*dx + dy*dy
+	if length2 == 0 { //the segment is a point
+		return math.Hypot(p.x-a.x, p.y-a.y)
+	} //if
+
+	//closest point on the segment
+	u := OutputClamp(((p.x-a.x)*dx+(p.y-a.y)*dy)/length2, 0, 1)
+	return math.Hypot(p.x-(a.x+u*dx), p.y-(a.y+u*dy))
+} //end distanceToSegment
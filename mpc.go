@@ -0,0 +1,212 @@
+//mpc
+//Created on: 10/19/2026
+//Model predictive controller that optimizes the voltages of both joints over a horizon
+
+package main
+
+import (
+	"math"
+)
+
+//MPC uses the LQR weights, holding each voltage for a fifth of the horizon
+func init() {
+	RegisterController("mpc", func(a2 Arm2) Controller {
+		mpc := newMPC(ctrlSettings.mpcHorizon, ctrlSettings.mpcHorizon/5, ctrlSettings.lqrQ, ctrlSettings.lqrR)
+		mpc.arm2 = a2
+		mpc.currentLimit = ctrlSettings.mpcCurrent
+		mpc.jointLimits = ctrlSettings.mpcLimits
+		return mpc
+	})
+} //end init
+
+//mpccontroller drives the arm by optimizing a voltage sequence against the arm's model
+type mpccontroller struct {
+	//configured attributes
+	arm2         Arm2      //arm to control
+	horizon      int       //number of time steps to look ahead
+	blockSize    int       //number of time steps each voltage in the sequence is held for
+	q            []float64 //state weights for [angle1, vel1, angle2, vel2]
+	r            []float64 //input weights for [voltage1, voltage2] away from the holding voltage
+	terminal     float64   //extra weight on the state at the end of the horizon
+	jointLimits  bool      //whether to keep predictions within the joints' limits
+	currentLimit float64   //maximum current per motor in Amps, 0 for no limit
+	iterations   int       //optimizer iterations per time step
+
+	//calculated attributes
+	u       [][2]float64 //voltage sequence, one pair per block
+	step    float64      //optimizer step size in Volts
+	elapsed int          //time steps the first block has been applied for
+} //end struct
+
+//weight on the squared distance past a joint limit
+const mpcLimitWeight = 1e6
+
+//Create a model predictive controller
+//int horizon - number of time steps to look ahead, at least one
+//int blockSize - number of time steps each voltage is held for
+//[]float64 q - state weights, one per state
+//[]float64 r - input weights, one per joint
+func newMPC(horizon, blockSize int, q, r []float64) *mpccontroller {
+	mpc := new(mpccontroller)
+	mpc.horizon = int(math.Max(1, float64(horizon)))
+	mpc.blockSize = int(math.Max(1, float64(blockSize)))
+	mpc.q = q
+	mpc.r = r
+	mpc.terminal = 10
+	mpc.iterations = 20
+	mpc.step = 2
+
+	blocks := (mpc.horizon + mpc.blockSize - 1) / mpc.blockSize
+	mpc.u = make([][2]float64, blocks)
+
+	return mpc
+} //end newMPC
+
+//Calculate the voltages for both joints
+//ArmState state - measured state of the arm
+//ArmState goal - state to drive the arm to
+//float64 t - simulation time in seconds
+//return - voltages for the first and second joints
+func (mpc *mpccontroller) calculate(state, goal ArmState, t float64) (float64, float64) {
+	return mpc.calcMPC(mpc.arm2, state, goal)
+} //end calculate
+
+//Forget the voltage sequence for the last goal
+//ArmState state - measured state of the arm
+//ArmState goal - state to drive the arm to
+//float64 t - simulation time in seconds
+func (mpc *mpccontroller) reset(state, goal ArmState, t float64) {
+	for i := range mpc.u {
+		mpc.u[i] = [2]float64{}
+	} //loop
+	mpc.step = 2
+	mpc.elapsed = 0
+} //end reset
+
+//Calculate the voltages for both joints by optimizing over the horizon
+//Arm2 a2 - arm being controlled
+//ArmState x - current state of the arm
+//ArmState goal - state to drive the arm to
+//return - voltages for the first and second joints
+func (mpc *mpccontroller) calcMPC(a2 Arm2, x ArmState, goal ArmState) (float64, float64) {
+	tau1, tau2 := a2.calcGravTorques(goal[0], goal[2])
+	hold := [2]float64{a2.arm1.torqueToVoltage(tau1), a2.arm2.torqueToVoltage(tau2)}
+
+	cost := mpc.calcCost(a2, x, goal, hold, mpc.u)
+	grad := make([][2]float64, len(mpc.u))
+	const h = 1e-3 //finite difference step in Volts
+
+	//projected gradient descent, warm started from the last solution
+	for it := 0; it < mpc.iterations; it++ {
+		maxGrad := 0.0
+		for i := range mpc.u {
+			for j := 0; j < 2; j++ {
+				//difference towards the inside of the voltage limits
+				dh := h
+				if mpc.u[i][j] >= MaxVoltage-h {
+					dh = -h
+				} //if
+				mpc.u[i][j] += dh
+				grad[i][j] = (mpc.calcCost(a2, x, goal, hold, mpc.u) - cost) / dh
+				mpc.u[i][j] -= dh
+
+				//voltages at a limit that want to go past it can't move
+				if (mpc.u[i][j] >= MaxVoltage && grad[i][j] < 0) || (mpc.u[i][j] <= -MaxVoltage && grad[i][j] > 0) {
+					grad[i][j] = 0
+				} //if
+				maxGrad = math.Max(maxGrad, math.Abs(grad[i][j]))
+			} //loop
+		} //loop
+		if maxGrad == 0 { //at the optimum
+			break
+		} //if
+
+		//take the largest step that lowers the cost
+		improved := false
+		for tries := 0; tries < 10 && !improved; tries++ {
+			next := make([][2]float64, len(mpc.u))
+			for i := range mpc.u {
+				for j := 0; j < 2; j++ {
+					next[i][j] = OutputClamp(mpc.u[i][j]-mpc.step*grad[i][j]/maxGrad, -MaxVoltage, MaxVoltage)
+				} //loop
+			} //loop
+
+			if nextCost := mpc.calcCost(a2, x, goal, hold, next); nextCost < cost {
+				mpc.u, cost = next, nextCost
+				mpc.step *= 1.5
+				improved = true
+			} else {
+				mpc.step /= 2
+			} //if
+		} //loop
+		mpc.step = math.Min(math.Max(mpc.step, 1e-3), MaxVoltage)
+	} //loop
+
+	v1, v2 := mpc.limitVoltages(a2, x, mpc.u[0][0], mpc.u[0][1])
+
+	//shift the sequence forward a block once the first has been held for its length, for the next warm start
+	if mpc.elapsed++; mpc.elapsed >= mpc.blockSize {
+		copy(mpc.u, mpc.u[1:])
+		mpc.elapsed = 0
+	} //if
+
+	return v1, v2
+} //end calcMPC
+
+//Calculate the cost of a voltage sequence by rolling the model forward over the horizon
+//Arm2 a2 - arm being controlled
+//ArmState x - current state of the arm
+//ArmState goal - state to drive the arm to
+//[2]float64 hold - voltages that hold the arm at the goal
+//[][2]float64 u - voltage sequence, one pair per block
+//return - the cost of the sequence
+func (mpc *mpccontroller) calcCost(a2 Arm2, x, goal ArmState, hold [2]float64, u [][2]float64) float64 {
+	cost := 0.0
+	for k := 0; k < mpc.horizon; k++ {
+		v1, v2 := mpc.limitVoltages(a2, x, u[k/mpc.blockSize][0], u[k/mpc.blockSize][1])
+		x = a2.predict(x, v1, v2)
+
+		weight := 1.0
+		if k == mpc.horizon-1 {
+			weight = mpc.terminal
+		} //if
+		for i := range x {
+			e := x[i] - goal[i]
+			cost += weight * mpc.q[i] * e * e
+		} //loop
+		cost += mpc.r[0]*(v1-hold[0])*(v1-hold[0]) + mpc.r[1]*(v2-hold[1])*(v2-hold[1])
+
+		if mpc.jointLimits {
+			cost += mpcLimitWeight * (limitViolation(*a2.arm1, x[0]) + limitViolation(*a2.arm2, x[2]))
+		} //if
+	} //loop
+	return cost
+} //end calcCost
+
+//Keep a pair of voltages within the voltage and current limits at a state
+//Arm2 a2 - arm being controlled
+//ArmState x - state the voltages are applied at
+//float64 v1 - voltage for the first joint
+//float64 v2 - voltage for the second joint
+//return - the limited voltages
+func (mpc *mpccontroller) limitVoltages(a2 Arm2, x ArmState, v1, v2 float64) (float64, float64) {
+	if mpc.currentLimit > 0 { //the current is set by the voltage across the resistance
+		band1 := mpc.currentLimit * a2.arm1.motor.kResistance
+		band2 := mpc.currentLimit * a2.arm2.motor.kResistance
+		v1 = math.Max(math.Min(v1, a2.arm1.calcBackEMF(x[1])+band1), a2.arm1.calcBackEMF(x[1])-band1)
+		v2 = math.Max(math.Min(v2, a2.arm2.calcBackEMF(x[3])+band2), a2.arm2.calcBackEMF(x[3])-band2)
+	} //if
+
+	return OutputClamp(v1, -MaxVoltage, MaxVoltage), OutputClamp(v2, -MaxVoltage, MaxVoltage)
+} //end limitVoltages
+
+//Calculate the squared distance an angle is past the limits of a joint
+//Arm a - joint to check
+//float64 angle - angle of the joint in radians
+func limitViolation(a Arm, angle float64) float64 {
+	if !a.limited {
+		return 0
+	} //if
+	over := math.Max(angle-a.maxAngle, 0) + math.Max(a.minAngle-angle, 0)
+	return over * over
+} //end limitViolation
@@ -0,0 +1,298 @@
+//order
+//Created on: 10/19/2026
+//Reordering the queued goals to visit them all in the least predicted time
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+//most goals ordered exactly, more than this are ordered by nearest neighbour and 2-opt
+const exactOrderLimit = 10
+
+//fixedEnds is which of the queued goals keep their place when reordering
+type fixedEnds int
+
+const (
+	fixNone  fixedEnds = iota //any goal can go anywhere
+	fixFirst                  //the first goal is still visited first
+	fixLast                   //the last goal is still visited last
+	fixBoth                   //the first and last goals keep their places
+)
+
+//get a string representation of the fixed ends
+func (f fixedEnds) String() string {
+	return [...]string{"none", "first", "last", "both"}[f]
+} //end String
+
+//Find which ends are fixed by name
+//string name - name of the fixed ends
+//return - the fixed ends, or an error if there are none with the name
+func parseFixedEnds(name string) (fixedEnds, error) {
+	for f := fixNone; f <= fixBoth; f++ {
+		if f.String() == name {
+			return f, nil
+		} //if
+	} //loop
+	return fixNone, fmt.Errorf("no fixed ends named %q, use none, first, last or both", name)
+} //end parseFixedEnds
+
+//Predict how long the arm takes to move between two poses
+//ikSolution from - pose to start at
+//ikSolution to - pose to end at
+//return - time for the move in seconds, the fastest the motors allow if moves are timed that way
+func (loop *ArmLoop) predictMoveTime(from, to ikSolution) float64 {
+	if loop.topp != nil && jointDistance(from, to) > 1e-6 {
+		//the same pairs come up every time a goal is added, and timing them optimally is slow
+		key := [2]ikSolution{from, to}
+		if t, ok := loop.moveTimes[key]; ok {
+			return t
+		} //if
+		if traj, err := loop.arm2.timeOptimal(jointPath{from, to}, *loop.topp); err == nil {
+			if loop.moveTimes == nil {
+				loop.moveTimes = map[[2]ikSolution]float64{}
+			} //if
+			loop.moveTimes[key] = traj.duration()
+			return traj.duration()
+		} //if
+	} //if
+	return loop.arm2.calcSegmentTimes(jointPath{from, to})[0]
+} //end predictMoveTime
+
+//Calculate the time to visit poses in an order
+//[][]float64 times - time from the start (index 0) or each pose (index i+1) to each pose
+//[]int order - order to visit the poses in
+//return - the total time in seconds
+func calcTourTime(times [][]float64, order []int) float64 {
+	total, at := 0.0, 0
+	for _, i := range order {
+		total += times[at][i+1]
+		at = i + 1
+	} //loop
+	return total
+} //end calcTourTime
+
+//Find the order to visit poses in that takes the least time, from a starting pose
+//[][]float64 times - time from the start (index 0) or each pose (index i+1) to each pose (index i+1)
+//fixedEnds fixed - which poses keep their place at the start and end of the order
+//return - the order to visit the poses in
+func orderTour(times [][]float64, fixed fixedEnds) []int {
+	n := len(times) - 1
+	if n < 2 {
+		return []int{0}[:n]
+	} //if
+
+	//the poses that can move and where the order starts and ends
+	first, last := -1, -1
+	if fixed == fixFirst || fixed == fixBoth {
+		first = 0
+	} //if
+	if fixed == fixLast || fixed == fixBoth {
+		last = n - 1
+	} //if
+	var free []int
+	for i := 0; i < n; i++ {
+		if i != first && i != last {
+			free = append(free, i)
+		} //if
+	} //loop
+
+	var middle []int
+	if len(free) <= exactOrderLimit {
+		middle = orderExact(times, free, first, last)
+	} else {
+		middle = orderHeuristic(times, free, first, last)
+	} //if
+
+	order := middle
+	if first >= 0 {
+		order = append([]int{first}, order...)
+	} //if
+	if last >= 0 {
+		order = append(order, last)
+	} //if
+	return order
+} //end orderTour
+
+//Find the best order of some poses exactly by dynamic programming over the subsets visited (Held-Karp)
+//[][]float64 times - time between the start and each pose
+//[]int free - poses to order
+//int first - pose visited before them, -1 for the start
+//int last - pose visited after them, -1 for none
+//return - the order to visit the free poses in
+func orderExact(times [][]float64, free []int, first, last int) []int {
+	m := len(free)
+	from := first + 1 //row of the times to leave from
+	full := 1<<uint(m) - 1
+
+	//cost[set][j] is the least time to visit the set ending at free pose j
+	cost := make([][]float64, full+1)
+	prev := make([][]int, full+1)
+	for set := range cost {
+		cost[set] = make([]float64, m)
+		prev[set] = make([]int, m)
+		for j := range cost[set] {
+			cost[set][j] = math.Inf(1)
+		} //loop
+	} //loop
+	for j := 0; j < m; j++ {
+		cost[1<<uint(j)][j] = times[from][free[j]+1]
+	} //loop
+
+	for set := 1; set <= full; set++ {
+		for j := 0; j < m; j++ {
+			if set&(1<<uint(j)) == 0 || math.IsInf(cost[set][j], 1) {
+				continue
+			} //if
+			for k := 0; k < m; k++ {
+				if set&(1<<uint(k)) != 0 {
+					continue
+				} //if
+				next := set | 1<<uint(k)
+				if c := cost[set][j] + times[free[j]+1][free[k]+1]; c < cost[next][k] {
+					cost[next][k], prev[next][k] = c, j
+				} //if
+			} //loop
+		} //loop
+	} //loop
+
+	//best pose to end on, counting the move to the fixed last pose
+	end, best := 0, math.Inf(1)
+	for j := 0; j < m; j++ {
+		c := cost[full][j]
+		if last >= 0 {
+			c += times[free[j]+1][last+1]
+		} //if
+		if c < best {
+			end, best = j, c
+		} //if
+	} //loop
+
+	//walk back through the subsets
+	order := make([]int, m)
+	for set, j, i := full, end, m-1; i >= 0; i-- {
+		order[i] = free[j]
+		set, j = set&^(1<<uint(j)), prev[set][j]
+	} //loop
+	return order
+} //end orderExact
+
+//Find a good order of many poses by visiting the nearest each time, then reversing stretches of it while that helps (2-opt)
+//[][]float64 times - time between the start and each pose
+//[]int free - poses to order
+//int first - pose visited before them, -1 for the start
+//int last - pose visited after them, -1 for none
+//return - the order to visit the free poses in
+func orderHeuristic(times [][]float64, free []int, first, last int) []int {
+	//time of the free poses in an order, between the fixed ends
+	tourTime := func(order []int) float64 {
+		full := append([]int{}, order...)
+		if first >= 0 {
+			full = append([]int{first}, full...)
+		} //if
+		if last >= 0 {
+			full = append(full, last)
+		} //if
+		return calcTourTime(times, full)
+	} //end tourTime
+
+	//nearest neighbour
+	left := append([]int{}, free...)
+	var order []int
+	at := first + 1
+	for len(left) > 0 {
+		best := 0
+		for i := range left {
+			if times[at][left[i]+1] < times[at][left[best]+1] {
+				best = i
+			} //if
+		} //loop
+		order = append(order, left[best])
+		at = left[best] + 1
+		left = append(left[:best], left[best+1:]...)
+	} //loop
+
+	//2-opt, checking the whole time since moves aren't the same both ways
+	for improved := true; improved; {
+		improved = false
+		bestTime := tourTime(order)
+		for i := 0; i < len(order)-1; i++ {
+			for j := i + 1; j < len(order); j++ {
+				trial := append([]int{}, order...)
+				for a, b := i, j; a < b; a, b = a+1, b-1 {
+					trial[a], trial[b] = trial[b], trial[a]
+				} //loop
+				if t := tourTime(trial); t < bestTime-1e-9 {
+					order, bestTime, improved = trial, t, true
+				} //if
+			} //loop
+		} //loop
+	} //loop
+	return order
+} //end orderHeuristic
+
+//reorderResult is an order found for the queued goals off the draw loop
+type reorderResult struct {
+	first     int                       //index in the queue of the first goal reordered
+	goals     []Goal                    //goals in the order they were queued
+	ordered   []Goal                    //goals in the new order
+	best      float64                   //predicted time to visit them in the new order in seconds
+	given     float64                   //predicted time to visit them in the order they were queued in seconds
+	moveTimes map[[2]ikSolution]float64 //move times known once they were ordered, to keep for next time
+} //end struct
+
+//Copy the state machine to order goals with off the draw loop, sharing nothing the draw loop changes
+//return - the copy, with its own arm and move times
+func (loop ArmLoop) orderingCopy() ArmLoop {
+	loop.arm2 = loop.arm2.withAngles(loop.arm2.arm1.angle, loop.arm2.arm2.angle)
+	if loop.arm2.arm3 != nil {
+		wrist := *loop.arm2.arm3
+		loop.arm2.arm3 = &wrist
+	} //if
+	if s, ok := loop.solver.(analyticSolver); ok { //solve with the copy instead
+		s.arm2 = loop.arm2
+		loop.solver = s
+	} //if
+
+	times := make(map[[2]ikSolution]float64, len(loop.moveTimes))
+	for k, v := range loop.moveTimes {
+		times[k] = v
+	} //loop
+	loop.moveTimes = times
+	return loop
+} //end orderingCopy
+
+//Reorder goals to visit them all in the least predicted time from a pose
+//ikSolution start - pose the arm starts from
+//[]Goal goals - goals to reorder
+//fixedEnds fixed - which goals keep their place at the start and end
+//return - the goals in the new order, and the predicted time to visit them all in it and in the old order in seconds
+func (loop *ArmLoop) orderGoals(start ikSolution, goals []Goal, fixed fixedEnds) ([]Goal, float64, float64) {
+	//each goal's joint angles from the start, as close as the arm can get to those it can't reach
+	poses := []ikSolution{start}
+	for _, g := range goals {
+		s, _, _ := loop.solveGoalFrom(g, start)
+		poses = append(poses, s)
+	} //loop
+
+	times := make([][]float64, len(poses))
+	for i := range poses {
+		times[i] = make([]float64, len(poses))
+		for j := 1; j < len(poses); j++ {
+			if i != j {
+				times[i][j] = loop.predictMoveTime(poses[i], poses[j])
+			} //if
+		} //loop
+	} //loop
+
+	order := orderTour(times, fixed)
+	ordered := make([]Goal, len(order))
+	given := make([]int, len(order))
+	for i, j := range order {
+		ordered[i] = goals[j]
+		given[i] = i
+	} //loop
+	return ordered, calcTourTime(times, order), calcTourTime(times, given)
+} //end orderGoals
@@ -19,6 +19,7 @@ type pidcontroller struct {
 	//calculated attributes
 	errorSum  float64 //sum of all errors
 	lastError float64 //last error for derivative calculation
+	started   bool    //whether lastError is from the current goal
 	epsilon   float64 //the range to be in to be considered "at goal"
 	atTarget  bool    //whether within epsilon bounds
 
@@ -45,12 +46,24 @@ func (pid *pidcontroller) calcPID(setpoint, current, epsilon float64) float64 {
 	iOut := pid.kI * pid.errorSum
 
 	//D value
+	if !pid.started { //no change in error yet on the first loop to a goal
+		pid.lastError = error
+		pid.started = true
+	} //if
 	dError := (error - pid.lastError)
 	dOut := pid.kD * dError
+	pid.lastError = error //difference from this error on the next loop
 
 	return pOut + iOut + dOut //sum of outputs
 } //end calcPID
 
+//clear the error sum and last error so the next goal starts without them
+func (pid *pidcontroller) reset() {
+	pid.errorSum = 0
+	pid.lastError = 0
+	pid.started = false
+} //end reset
+
 //calculate the voltage required to hold an arm up at a certain angle
 //Arm a - arm to hold up
 func calcFFArm(a *Arm) float64 {
@@ -0,0 +1,235 @@
+//planner
+//Created on: 10/19/2026
+//Joint space path planning around obstacles with RRT-Connect, then shortcutting and smoothing the path
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+//no path was found before running out of iterations
+var errNoPath = errors.New("no collision-free path found")
+
+//planner finds paths through joint space that keep the arm clear of obstacles
+type planner struct {
+	arm2          Arm2       //arm to plan for, with its joint limits
+	obstacles     []obstacle //obstacles the arm can't pass through
+	step          float64    //furthest the trees grow towards a sample in radians
+	resolution    float64    //spacing of the poses checked along each edge in radians
+	maxIterations int        //most samples to take before giving up
+	smoothing     int        //passes of corner cutting on the shortcut path
+	rng           *rand.Rand //source of the samples
+} //end struct
+
+//Create a planner for an arm
+//Arm2 a2 - arm to plan for
+//[]obstacle obstacles - obstacles the arm can't pass through
+//int64 seed - seed for the samples, the same seed plans the same paths
+func newPlanner(a2 Arm2, obstacles []obstacle, seed int64) *planner {
+	return &planner{arm2: a2, obstacles: obstacles, step: ToRadians(10), resolution: ToRadians(1),
+		maxIterations: 5000, smoothing: 2, rng: rand.New(rand.NewSource(seed))}
+} //end newPlanner
+
+//Calculate the distance between two poses in joint space
+//ikSolution a - first pose
+//ikSolution b - second pose
+//return - the distance in radians
+func jointDistance(a, b ikSolution) float64 {
+	return math.Hypot(b.q1-a.q1, b.q2-a.q2)
+} //end jointDistance
+
+//Interpolate between two poses in joint space
+//ikSolution a - pose at u=0
+//ikSolution b - pose at u=1
+//float64 u - fraction of the way from a to b
+func lerpJoints(a, b ikSolution, u float64) ikSolution {
+	return ikSolution{a.q1 + u*(b.q1-a.q1), a.q2 + u*(b.q2-a.q2)}
+} //end lerpJoints
+
+//Check if the arm can hold a pose
+//ikSolution q - joint angles to check
+func (p planner) isValid(q ikSolution) bool {
+	return p.arm2.isValidPose(q.q1, q.q2, p.obstacles)
+} //end isValid
+
+//Check if the arm can move straight through joint space between two poses
+//ikSolution a - pose to move from
+//ikSolution b - pose to move to
+//return - whether every pose along the way is valid
+func (p planner) edgeValid(a, b ikSolution) bool {
+	n := int(math.Ceil(jointDistance(a, b) / p.resolution))
+	for i := 0; i <= n; i++ {
+		if !p.isValid(lerpJoints(a, b, float64(i)/math.Max(float64(n), 1))) {
+			return false
+		} //if
+	} //loop
+	return true
+} //end edgeValid
+
+//rrtTree is a tree of valid poses grown from a root
+type rrtTree struct {
+	nodes   []ikSolution //poses in the tree, the root first
+	parents []int        //index of each pose's parent, -1 for the root
+} //end struct
+
+//Add a pose to the tree
+//ikSolution q - pose to add
+//int parent - index of the pose it was grown from
+//return - index of the new pose
+func (tree *rrtTree) add(q ikSolution, parent int) int {
+	tree.nodes = append(tree.nodes, q)
+	tree.parents = append(tree.parents, parent)
+	return len(tree.nodes) - 1
+} //end add
+
+//Find the pose in the tree closest to another
+//ikSolution q - pose to be close to
+//return - index of the closest pose
+func (tree rrtTree) nearest(q ikSolution) int {
+	best, bestDist := 0, math.Inf(1)
+	for i, n := range tree.nodes {
+		if d := jointDistance(n, q); d < bestDist {
+			best, bestDist = i, d
+		} //if
+	} //loop
+	return best
+} //end nearest
+
+//Get the poses from the root of the tree to one of its poses
+//int i - index of the pose to end at
+//return - the path from the root
+func (tree rrtTree) pathTo(i int) jointPath {
+	var path jointPath
+	for ; i >= 0; i = tree.parents[i] {
+		path = append(jointPath{tree.nodes[i]}, path...)
+	} //loop
+	return path
+} //end pathTo
+
+//Grow a tree one step towards a pose
+//*rrtTree tree - tree to grow
+//ikSolution q - pose to grow towards
+//return - index of the new pose, -1 if the way is blocked, and whether it reached the pose
+func (p planner) extend(tree *rrtTree, q ikSolution) (int, bool) {
+	near := tree.nearest(q)
+	from := tree.nodes[near]
+	to, reached := q, true
+	if d := jointDistance(from, q); d > p.step {
+		to, reached = lerpJoints(from, q, p.step/d), false
+	} //if
+
+	if !p.edgeValid(from, to) {
+		return -1, false
+	} //if
+	return tree.add(to, near), reached
+} //end extend
+
+//Grow a tree towards a pose until it reaches it or is blocked
+//*rrtTree tree - tree to grow
+//ikSolution q - pose to grow towards
+//return - index of the last pose added, -1 if none were, and whether it reached the pose
+func (p planner) connect(tree *rrtTree, q ikSolution) (int, bool) {
+	last := -1
+	for {
+		i, reached := p.extend(tree, q)
+		if i < 0 {
+			return last, false
+		} //if
+		last = i
+		if reached {
+			return last, true
+		} //if
+	} //loop
+} //end connect
+
+//Sample a pose within the joint limits, or anywhere between the start and goal and a turn either way without them
+//ikSolution start - pose the path starts at
+//ikSolution goal - pose the path ends at
+func (p planner) sample(start, goal ikSolution) ikSolution {
+	min1, max1 := jointRange(*p.arm2.arm1)
+	min2, max2 := jointRange(*p.arm2.arm2)
+	min1, max1 = math.Min(min1, math.Min(start.q1, goal.q1)), math.Max(max1, math.Max(start.q1, goal.q1))
+	min2, max2 = math.Min(min2, math.Min(start.q2, goal.q2)), math.Max(max2, math.Max(start.q2, goal.q2))
+	return ikSolution{min1 + p.rng.Float64()*(max1-min1), min2 + p.rng.Float64()*(max2-min2)}
+} //end sample
+
+//Plan a path between two poses that keeps the arm clear of the obstacles
+//ikSolution start - pose to start at
+//ikSolution goal - pose to end at
+//return - the shortcut and smoothed path from the start to the goal, or an error if none was found
+func (p planner) plan(start, goal ikSolution) (jointPath, error) {
+	if !p.isValid(start) {
+		return nil, fmt.Errorf("start (%.1f, %.1f) deg hits an obstacle", ToDegrees(start.q1), ToDegrees(start.q2))
+	} //if
+	if !p.isValid(goal) {
+		return nil, fmt.Errorf("goal (%.1f, %.1f) deg hits an obstacle", ToDegrees(goal.q1), ToDegrees(goal.q2))
+	} //if
+	if p.edgeValid(start, goal) { //nothing in the way
+		return jointPath{start, goal}, nil
+	} //if
+
+	//grow a tree from each end, each reaching for the other
+	a, b := &rrtTree{}, &rrtTree{}
+	a.add(start, -1)
+	b.add(goal, -1)
+	for i := 0; i < p.maxIterations; i++ {
+		if added, _ := p.extend(a, p.sample(start, goal)); added >= 0 {
+			if met, reached := p.connect(b, a.nodes[added]); reached {
+				//the start tree's path, then the goal tree's path backwards
+				fromA, fromB := a.pathTo(added), b.pathTo(met)
+				if a.nodes[0] != start {
+					fromA, fromB = fromB, fromA
+				} //if
+				path := append(jointPath{}, fromA...)
+				for j := len(fromB) - 2; j >= 0; j-- { //the meeting pose is already in the path
+					path = append(path, fromB[j])
+				} //loop
+				return p.smooth(p.shortcut(path)), nil
+			} //if
+		} //if
+		a, b = b, a
+	} //loop
+
+	return nil, errNoPath
+} //end plan
+
+//Remove the detours from a path, going straight to the furthest pose each pose can reach
+//jointPath path - path to shorten
+//return - the shortcut path with the same start and goal
+func (p planner) shortcut(path jointPath) jointPath {
+	short := jointPath{path[0]}
+	for i := 0; i < len(path)-1; {
+		j := len(path) - 1
+		for j > i+1 && !p.edgeValid(path[i], path[j]) {
+			j--
+		} //loop
+		short = append(short, path[j])
+		i = j
+	} //loop
+	return short
+} //end shortcut
+
+//Round off the corners of a path by cutting each one where the cut stays clear of the obstacles
+//jointPath path - path to smooth
+//return - the smoothed path with the same start and goal
+func (p planner) smooth(path jointPath) jointPath {
+	for pass := 0; pass < p.smoothing; pass++ {
+		smooth := jointPath{path[0]}
+		for i := 1; i < len(path)-1; i++ {
+			//a quarter of the way back along each side of the corner
+			before := lerpJoints(path[i], path[i-1], 0.25)
+			after := lerpJoints(path[i], path[i+1], 0.25)
+			if p.edgeValid(before, after) {
+				smooth = append(smooth, before, after)
+			} else {
+				smooth = append(smooth, path[i])
+			} //if
+		} //loop
+		path = append(smooth, path[len(path)-1])
+	} //loop
+	return path
+} //end smooth
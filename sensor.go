@@ -0,0 +1,36 @@
+//sensor
+//Created on: 10/19/2026
+//Model of an imperfect joint encoder
+
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+//encoder measures the angle of a joint with noise and a limited resolution
+type encoder struct {
+	noise      float64    //standard deviation of the measurement noise in radians
+	resolution float64    //smallest change in angle the encoder can measure in radians, 0 for perfect
+	rng        *rand.Rand //random source for the noise, nil to use the global source
+} //end struct
+
+//Measure the angle of a joint
+//float64 angle - true angle of the joint in radians
+//return - the measured angle
+func (e encoder) measure(angle float64) float64 {
+	if e.noise > 0 {
+		if e.rng != nil {
+			angle += e.rng.NormFloat64() * e.noise
+		} else {
+			angle += rand.NormFloat64() * e.noise
+		} //if
+	} //if
+
+	if e.resolution > 0 { //round to the nearest count
+		angle = math.Round(angle/e.resolution) * e.resolution
+	} //if
+
+	return angle
+} //end measure
@@ -0,0 +1,280 @@
+//spline
+//Created on: 10/19/2026
+//Cubic and quintic splines through a list of waypoints, in joint or Cartesian space
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+//armTrajectory is a trajectory for both joints of the arm
+type armTrajectory interface {
+	//Sample both joints at a point in time
+	//float64 t - time since the start of the trajectory in seconds
+	//return - desired position, velocity and acceleration of the first and second joints
+	sample(t float64) (jointSetpoint, jointSetpoint)
+
+	//Get the time to move through the whole trajectory
+	//return - duration in seconds
+	duration() float64
+} //end interface
+
+//splineKind is the order of the polynomial between each pair of waypoints
+type splineKind int
+
+const (
+	cubicSpline   splineKind = iota //continuous velocity
+	quinticSpline                   //continuous velocity and acceleration
+)
+
+//get a string representation of the spline kind
+func (k splineKind) String() string {
+	return [...]string{"cubic", "quintic"}[k]
+} //end String
+
+//splineSpace is the space the spline is fit in
+type splineSpace int
+
+const (
+	jointSpace     splineSpace = iota //each joint angle is a spline, the end point curves between waypoints
+	cartesianSpace                    //the end point is a spline, the joints follow through the inverse kinematics
+)
+
+//get a string representation of the spline space
+func (s splineSpace) String() string {
+	return [...]string{"joint", "cartesian"}[s]
+} //end String
+
+//splineOptions is how to fit a spline through waypoints
+type splineOptions struct {
+	kind     splineKind  //order of the polynomials
+	space    splineSpace //space to fit in
+	velocity float64     //velocity at each intermediate waypoint as a fraction of the average either side, 0 to stop at each
+
+	throughEnds bool //whether to keep moving through the first and last waypoints instead of starting and ending at rest
+} //end struct
+
+//Find the spline options by name
+//string kind - cubic or quintic
+//string space - joint or cartesian
+//float64 velocity - velocity at the intermediate waypoints as a fraction of the average either side
+//return - the options, or an error if either name is unknown
+func parseSplineOptions(kind, space string, velocity float64) (splineOptions, error) {
+	opts := splineOptions{velocity: velocity}
+	switch kind {
+	case "cubic":
+		opts.kind = cubicSpline
+	case "quintic":
+		opts.kind = quinticSpline
+	default:
+		return opts, fmt.Errorf("no spline named %q, use cubic or quintic", kind)
+	} //switch
+
+	switch space {
+	case "joint":
+		opts.space = jointSpace
+	case "cartesian":
+		opts.space = cartesianSpace
+	default:
+		return opts, fmt.Errorf("no spline space named %q, use joint or cartesian", space)
+	} //switch
+	return opts, nil
+} //end parseSplineOptions
+
+//polySegment is a polynomial in time between two waypoints
+type polySegment struct {
+	coeffs   [6]float64 //coefficients from the constant term up
+	duration float64    //length of the segment in seconds
+} //end struct
+
+//Fit a polynomial between two waypoints with a position, velocity and acceleration at each end
+//splineKind kind - cubic ignores the accelerations, quintic meets them
+//jointSetpoint from - where the segment starts
+//jointSetpoint to - where the segment ends
+//float64 T - length of the segment in seconds
+func newPolySegment(kind splineKind, from, to jointSetpoint, T float64) polySegment {
+	seg := polySegment{duration: T}
+	if T <= 0 { //stay put
+		seg.coeffs[0] = to.pos
+		return seg
+	} //if
+
+	p0, v0, a0, p1, v1, a1 := from.pos, from.vel, from.acc, to.pos, to.vel, to.acc
+	seg.coeffs[0], seg.coeffs[1] = p0, v0
+	if kind == cubicSpline {
+		seg.coeffs[2] = (3*(p1-p0)/T - 2*v0 - v1) / T
+		seg.coeffs[3] = (2*(p0-p1)/T + v0 + v1) / (T * T)
+		return seg
+	} //if
+
+	seg.coeffs[2] = a0 / 2
+	seg.coeffs[3] = (20*(p1-p0) - (8*v1+12*v0)*T - (3*a0-a1)*T*T) / (2 * math.Pow(T, 3))
+	seg.coeffs[4] = (30*(p0-p1) + (14*v1+16*v0)*T + (3*a0-2*a1)*T*T) / (2 * math.Pow(T, 4))
+	seg.coeffs[5] = (12*(p1-p0) - 6*(v1+v0)*T - (a0-a1)*T*T) / (2 * math.Pow(T, 5))
+	return seg
+} //end newPolySegment
+
+//Sample the segment at a point in time
+//float64 t - time since the start of the segment in seconds, held at the ends
+//return - position, velocity and acceleration
+func (seg polySegment) sample(t float64) jointSetpoint {
+	t = math.Max(0, math.Min(seg.duration, t))
+	var sp jointSetpoint
+	for i := len(seg.coeffs) - 1; i >= 0; i-- { //Horner's method for all three
+		sp.acc = sp.acc*t + 2*sp.vel
+		sp.vel = sp.vel*t + sp.pos
+		sp.pos = sp.pos*t + seg.coeffs[i]
+	} //loop
+	return sp
+} //end sample
+
+//pathTrajectory moves both joints along splines through a path
+type pathTrajectory struct {
+	segments [][2]polySegment //polynomials of both joints between each pair of points
+	starts   []float64        //time each segment starts in seconds
+} //end struct
+
+//Fit splines through waypoints, one for each of two values
+//jointPath knots - waypoints to pass through, at least one
+//[]float64 durations - time between each pair of waypoints in seconds
+//splineOptions opts - kind of spline and velocity at the waypoints
+//return - the splines
+func fitSpline(knots jointPath, durations []float64, opts splineOptions) pathTrajectory {
+	if len(knots) == 1 { //already there
+		knots, durations = jointPath{knots[0], knots[0]}, []float64{0}
+	} //if
+
+	//velocity and acceleration at each waypoint, finite differences of the waypoints either side
+	n := len(knots)
+	values := [2][]float64{make([]float64, n), make([]float64, n)}
+	for i, k := range knots {
+		values[0][i], values[1][i] = k.q1, k.q2
+	} //loop
+	var setpoints [2][]jointSetpoint
+	for j := range values {
+		v := values[j]
+		setpoints[j] = make([]jointSetpoint, n)
+		for i := range v {
+			setpoints[j][i].pos = v[i]
+			if opts.throughEnds && i == 0 && durations[0] > 0 { //heading straight for the next waypoint
+				setpoints[j][i].vel = opts.velocity * (v[1] - v[0]) / durations[0]
+			} //if
+			if opts.throughEnds && i == n-1 && durations[n-2] > 0 { //coming straight from the last waypoint
+				setpoints[j][i].vel = opts.velocity * (v[n-1] - v[n-2]) / durations[n-2]
+			} //if
+			if i == 0 || i == n-1 || durations[i-1] <= 0 || durations[i] <= 0 { //ends and repeated waypoints
+				continue
+			} //if
+			before, after := (v[i]-v[i-1])/durations[i-1], (v[i+1]-v[i])/durations[i]
+			total := durations[i-1] + durations[i]
+			setpoints[j][i].vel = opts.velocity * (v[i+1] - v[i-1]) / total
+			setpoints[j][i].acc = opts.velocity * 2 * (after - before) / total
+		} //loop
+	} //loop
+
+	var traj pathTrajectory
+	t := 0.0
+	for i := 0; i < n-1; i++ {
+		traj.segments = append(traj.segments, [2]polySegment{
+			newPolySegment(opts.kind, setpoints[0][i], setpoints[0][i+1], durations[i]),
+			newPolySegment(opts.kind, setpoints[1][i], setpoints[1][i+1], durations[i])})
+		traj.starts = append(traj.starts, t)
+		t += durations[i]
+	} //loop
+	return traj
+} //end fitSpline
+
+//Calculate the time for each segment of a path, both joints taking the same time so they arrive together
+//jointPath path - joint angles to pass through
+//return - time between each pair of points in seconds
+func (a2 Arm2) calcSegmentTimes(path jointPath) []float64 {
+	durations := make([]float64, 0, len(path))
+	for i := 1; i < len(path); i++ {
+		from, to := path[i-1], path[i]
+		durations = append(durations, math.Max(a2.arm1.calcMoveTime(to.q1-from.q1), a2.arm2.calcMoveTime(to.q2-from.q2)))
+	} //loop
+	return durations
+} //end calcSegmentTimes
+
+//Create a trajectory through a path that comes to rest at each point along it
+//jointPath path - joint angles to pass through, at least one
+func (a2 Arm2) newPathTrajectory(path jointPath) pathTrajectory {
+	return fitSpline(path, a2.calcSegmentTimes(path), splineOptions{kind: quinticSpline})
+} //end newPathTrajectory
+
+//Sample both joints at a point in time
+//float64 t - time since the start of the trajectory in seconds
+//return - desired position, velocity and acceleration of the first and second joints
+func (traj pathTrajectory) sample(t float64) (jointSetpoint, jointSetpoint) {
+	i := 0
+	for i+1 < len(traj.segments) && t >= traj.starts[i+1] {
+		i++
+	} //loop
+	seg := traj.segments[i]
+	return seg[0].sample(t - traj.starts[i]), seg[1].sample(t - traj.starts[i])
+} //end sample
+
+//Get the time to move through the whole path
+//return - duration in seconds
+func (traj pathTrajectory) duration() float64 {
+	last := len(traj.segments) - 1
+	return traj.starts[last] + traj.segments[last][0].duration
+} //end duration
+
+//cartesianTrajectory moves the end point along a spline, with the joints following through the inverse kinematics
+type cartesianTrajectory struct {
+	arm2  Arm2           //arm following the trajectory
+	path  pathTrajectory //splines of the end point's x and y
+	elbow float64        //sign of the second joint's angle, so the elbow doesn't flip along the way
+	ref   float64        //angle of the first joint at the start, so it doesn't jump a whole turn
+} //end struct
+
+//Fit a spline for the end point through a path
+//jointPath path - joint angles to pass through, at least one
+//splineOptions opts - kind of spline and velocity at the intermediate waypoints
+func (a2 Arm2) newCartesianTrajectory(path jointPath, opts splineOptions) cartesianTrajectory {
+	knots := make(jointPath, len(path))
+	for i, q := range path {
+		tip := a2.calcEndPoint(q.q1, q.q2)
+		knots[i] = ikSolution{tip.x, tip.y}
+	} //loop
+
+	elbow := 1.0
+	if math.Sin(path[0].q2) < 0 {
+		elbow = -1
+	} //if
+	return cartesianTrajectory{a2, fitSpline(knots, a2.calcSegmentTimes(path), opts), elbow, path[0].q1}
+} //end newCartesianTrajectory
+
+//Calculate the joint angles that put the end point on the spline at a point in time
+//float64 t - time since the start of the trajectory in seconds
+func (traj cartesianTrajectory) anglesAt(t float64) ikSolution {
+	x, y := traj.path.sample(t)
+	solutions := InverseKinematicsAll(nearestReachable(Point{x.pos, y.pos}, traj.arm2.arm1.length, traj.arm2.arm2.length),
+		traj.arm2.arm1.length, traj.arm2.arm2.length)
+	best := solutions[0]
+	for _, s := range solutions {
+		if math.Sin(s.q2)*traj.elbow > 0 {
+			best = s
+		} //if
+	} //loop
+	return ikSolution{nearestTurn(best.q1, traj.ref), best.q2}
+} //end anglesAt
+
+//Sample both joints at a point in time, differentiating the joint angles numerically
+//float64 t - time since the start of the trajectory in seconds
+//return - desired position, velocity and acceleration of the first and second joints
+func (traj cartesianTrajectory) sample(t float64) (jointSetpoint, jointSetpoint) {
+	const h = 1e-3 //time step for the differences in seconds
+	before, now, after := traj.anglesAt(t-h), traj.anglesAt(t), traj.anglesAt(t+h)
+	return jointSetpoint{now.q1, (after.q1 - before.q1) / (2 * h), (after.q1 - 2*now.q1 + before.q1) / (h * h)},
+		jointSetpoint{now.q2, (after.q2 - before.q2) / (2 * h), (after.q2 - 2*now.q2 + before.q2) / (h * h)}
+} //end sample
+
+//Get the time to move through the whole trajectory
+//return - duration in seconds
+func (traj cartesianTrajectory) duration() float64 {
+	return traj.path.duration()
+} //end duration
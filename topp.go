@@ -0,0 +1,213 @@
+//topp
+//Created on: 10/19/2026
+//Time-optimal timing along a path for the two-jointed arm within its motors' voltage and current limits
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+//number of steps the path is split into for the timing
+const toppSteps = 200
+
+//the arm can't hold itself somewhere along the path
+var errPathInfeasible = errors.New("path needs more than the motor limits to hold the arm")
+
+//toppLimits are the limits on each joint's motors while timing a path
+type toppLimits struct {
+	voltage float64 //most voltage either joint can apply, less than the battery to leave room for feedback
+	current float64 //most current through each motor in Amps, 0 for no limit
+} //end struct
+
+//geometricPath is a smooth curve through joint space, without any timing
+type geometricPath struct {
+	spline pathTrajectory //joint angles against the distance along the path
+	length float64        //distance along the path from one end to the other in radians
+} //end struct
+
+//Fit a smooth curve through joint angles, parameterized by roughly the distance along it
+//jointPath path - joint angles to pass through, at least two
+func newGeometricPath(path jointPath) geometricPath {
+	lengths := make([]float64, len(path)-1)
+	total := 0.0
+	for i := range lengths {
+		lengths[i] = math.Max(jointDistance(path[i], path[i+1]), 1e-9)
+		total += lengths[i]
+	} //loop
+	return geometricPath{fitSpline(path, lengths, splineOptions{kind: quinticSpline, velocity: 1, throughEnds: true}), total}
+} //end newGeometricPath
+
+//Get the joint angles and their first and second derivatives with respect to the distance along the path
+//float64 s - distance along the path in radians
+//return - the first and second joints, with vel as the first derivative and acc as the second
+func (p geometricPath) at(s float64) (jointSetpoint, jointSetpoint) {
+	return p.spline.sample(s)
+} //end at
+
+//toppTrajectory is the fastest timing along a path, sampled at even steps along it
+type toppTrajectory struct {
+	path  geometricPath //path being timed
+	speed []float64     //rate along the path at each step in radians/second
+	times []float64     //time each step is reached in seconds
+} //end struct
+
+//Calculate the joint setpoints for a point along the path moving at some rate
+//geometricPath path - path being followed
+//float64 s - distance along the path
+//float64 sd - rate along the path
+//float64 sdd - acceleration along the path
+//return - setpoints of the first and second joints
+func (p geometricPath) setpointsAt(s, sd, sdd float64) (jointSetpoint, jointSetpoint) {
+	q1, q2 := p.at(s)
+	return jointSetpoint{q1.pos, q1.vel * sd, q1.vel*sdd + q1.acc*sd*sd},
+		jointSetpoint{q2.pos, q2.vel * sd, q2.vel*sdd + q2.acc*sd*sd}
+} //end setpointsAt
+
+//Calculate the range of accelerations along the path the motors can produce at a point and rate
+//geometricPath path - path being timed
+//float64 s - distance along the path
+//float64 sd - rate along the path
+//toppLimits limits - limits on the motors
+//return - lowest and highest accelerations along the path, the lowest above the highest if there are none
+func (a2 Arm2) calcPathAccelRange(path geometricPath, s, sd float64, limits toppLimits) (float64, float64) {
+	lo, hi := math.Inf(-1), math.Inf(1)
+
+	//the voltages are linear in the acceleration along the path
+	sp1, sp2 := path.setpointsAt(s, sd, 0)
+	b1, b2 := calcIDFFArm2(a2, sp1, sp2)
+	sp1, sp2 = path.setpointsAt(s, sd, 1)
+	v1, v2 := calcIDFFArm2(a2, sp1, sp2)
+
+	for _, j := range []struct {
+		arm     *Arm
+		a, b    float64
+		jointSp jointSetpoint
+	}{{a2.arm1, v1 - b1, b1, sp1}, {a2.arm2, v2 - b2, b2, sp2}} {
+		//voltage within the battery, and close enough to the back-EMF to keep the current down
+		low, high := -limits.voltage, limits.voltage
+		if limits.current > 0 {
+			emf := j.arm.calcBackEMF(j.jointSp.vel)
+			low = math.Max(low, emf-limits.current*j.arm.motor.kResistance)
+			high = math.Min(high, emf+limits.current*j.arm.motor.kResistance)
+		} //if
+
+		if math.Abs(j.a) < 1e-12 { //not accelerating along the path, only the rate matters
+			if j.b < low || j.b > high {
+				return 1, -1
+			} //if
+			continue
+		} //if
+		from, to := (low-j.b)/j.a, (high-j.b)/j.a
+		lo, hi = math.Max(lo, math.Min(from, to)), math.Min(hi, math.Max(from, to))
+	} //loop
+	return lo, hi
+} //end calcPathAccelRange
+
+//Find the fastest the arm can move along the path at a point and still stay within its limits
+//geometricPath path - path being timed
+//float64 s - distance along the path
+//toppLimits limits - limits on the motors
+//return - the highest rate along the path in radians/second, negative if it can't even hold still
+func (a2 Arm2) calcMaxPathSpeed(path geometricPath, s float64, limits toppLimits) float64 {
+	feasible := func(sd float64) bool {
+		lo, hi := a2.calcPathAccelRange(path, s, sd, limits)
+		return lo <= hi
+	} //end feasible
+	if !feasible(0) {
+		return -1
+	} //if
+
+	//double until it is too fast, then bisect
+	low, high := 0.0, 1.0
+	for feasible(high) && high < 1e3 {
+		low, high = high, high*2
+	} //loop
+	for i := 0; i < 40; i++ {
+		mid := (low + high) / 2
+		if feasible(mid) {
+			low = mid
+		} else {
+			high = mid
+		} //if
+	} //loop
+	return low
+} //end calcMaxPathSpeed
+
+//Find the fastest timing along a path, starting and ending at rest, within the motor limits
+//jointPath path - joint angles to pass through, at least two
+//toppLimits limits - limits on the motors
+//return - the time-optimal trajectory, or an error if the arm can't hold itself along the path
+func (a2 Arm2) timeOptimal(path jointPath, limits toppLimits) (toppTrajectory, error) {
+	geo := newGeometricPath(path)
+	ds := geo.length / toppSteps
+
+	//the fastest the arm can go at each step without breaking a limit
+	ceiling := make([]float64, toppSteps+1)
+	for k := range ceiling {
+		if ceiling[k] = a2.calcMaxPathSpeed(geo, float64(k)*ds, limits); ceiling[k] < 0 {
+			return toppTrajectory{}, errPathInfeasible
+		} //if
+	} //loop
+
+	//accelerate as hard as possible from the start, then brake as hard as possible into the end,
+	//each step's acceleration within the limits at both of its ends
+	speed := make([]float64, toppSteps+1)
+	for k := 0; k < toppSteps; k++ {
+		_, hi := a2.calcPathAccelRange(geo, float64(k)*ds, speed[k], limits)
+		for i := 0; i < 3; i++ {
+			speed[k+1] = math.Min(ceiling[k+1], math.Sqrt(math.Max(0, speed[k]*speed[k]+2*ds*hi)))
+			_, next := a2.calcPathAccelRange(geo, float64(k+1)*ds, speed[k+1], limits)
+			hi = math.Min(hi, next)
+		} //loop
+	} //loop
+	speed[toppSteps] = 0
+	for k := toppSteps - 1; k >= 0; k-- {
+		lo, _ := a2.calcPathAccelRange(geo, float64(k+1)*ds, speed[k+1], limits)
+		for i := 0; i < 3; i++ {
+			braked := math.Min(speed[k], math.Sqrt(math.Max(0, speed[k+1]*speed[k+1]-2*ds*lo)))
+			prev, _ := a2.calcPathAccelRange(geo, float64(k)*ds, braked, limits)
+			lo = math.Max(lo, prev)
+		} //loop
+		speed[k] = math.Min(speed[k], math.Sqrt(math.Max(0, speed[k+1]*speed[k+1]-2*ds*lo)))
+	} //loop
+
+	//time for each step at the average rate across it
+	traj := toppTrajectory{path: geo, speed: speed, times: make([]float64, toppSteps+1)}
+	for k := 0; k < toppSteps; k++ {
+		avg := (speed[k] + speed[k+1]) / 2
+		if avg <= 0 {
+			return toppTrajectory{}, fmt.Errorf("arm stalls %.0f%% of the way along the path", 100*float64(k)/toppSteps)
+		} //if
+		traj.times[k+1] = traj.times[k] + ds/avg
+	} //loop
+	return traj, nil
+} //end timeOptimal
+
+//Sample both joints at a point in time, accelerating evenly along the path within each step
+//float64 t - time since the start of the trajectory in seconds
+//return - desired position, velocity and acceleration of the first and second joints
+func (traj toppTrajectory) sample(t float64) (jointSetpoint, jointSetpoint) {
+	ds := traj.path.length / toppSteps
+	if t >= traj.duration() { //at rest at the end
+		return traj.path.setpointsAt(traj.path.length, 0, 0)
+	} //if
+	t = math.Max(0, t)
+
+	k := 0
+	for k+1 < toppSteps && t >= traj.times[k+1] {
+		k++
+	} //loop
+	tau := t - traj.times[k]
+	sdd := (traj.speed[k+1]*traj.speed[k+1] - traj.speed[k]*traj.speed[k]) / (2 * ds)
+	s := float64(k)*ds + traj.speed[k]*tau + 0.5*sdd*tau*tau
+	return traj.path.setpointsAt(math.Min(s, traj.path.length), traj.speed[k]+sdd*tau, sdd)
+} //end sample
+
+//Get the time to move along the whole path
+//return - duration in seconds
+func (traj toppTrajectory) duration() float64 {
+	return traj.times[toppSteps]
+} //end duration
@@ -0,0 +1,26 @@
+//trajectory
+//Created on: 10/19/2026
+//Smooth joint-space trajectories for the arm to follow
+
+package main
+
+import (
+	"math"
+)
+
+//jointPath is joint angles for the arm to pass through in order
+type jointPath []ikSolution
+
+//Calculate a time to move the arm a distance using a fraction of its speed and torque
+//float64 distance - angle to move in radians
+//return - duration of the move in seconds
+func (a Arm) calcMoveTime(distance float64) float64 {
+	distance = math.Abs(distance)
+	maxAcc := (MaxVoltage * a.gearRatio * a.kT) / (a.motor.kResistance * a.moi) //acceleration at stall
+
+	//peak velocity of the profile is 1.875d/T and peak acceleration 5.77d/T^2
+	velTime := 1.875 * distance / (0.5 * a.maxVel)
+	accTime := math.Sqrt(5.77 * distance / (0.25 * maxAcc))
+
+	return math.Max(velTime, accTime)
+} //end calcMoveTime
@@ -0,0 +1,280 @@
+//tuning
+//Created on: 10/19/2026
+//Automatic tuning of the joint PID gains by running the simulation headlessly
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+//length of each simulated step response in seconds
+const tuneDuration = 4.0
+
+//Simulate one joint stepping to a target with PID and the chain feedforward
+//int joint - joint being stepped, 1 or 2
+//pidcontroller gains - gains for the joint being stepped
+//ArmState start - state both joints start at
+//float64 target - angle to step the joint to in radians
+//return - the response of the stepped joint
+func simulateStep(joint int, gains pidcontroller, start ArmState, target float64) stepResponse {
+	a2 := makeArm2()
+	a2.arm1.angle, a2.arm2.angle = start[0], start[2]
+	a2.update()
+
+	arm, goal := a2.arm1, ArmState{target, 0, start[2], 0}
+	if joint == 2 {
+		arm, goal = a2.arm2, ArmState{start[0], 0, target, 0}
+	} //if
+	arm.pid = pidcontroller{kP: gains.kP, kI: gains.kI, kD: gains.kD}
+
+	c := &pidFFController{arm2: a2}
+	r := stepResponse{start: arm.angle, target: target, motors: arm.numMotors}
+	for t := 0.0; t < tuneDuration; t += dt {
+		a2.arm1.voltage, a2.arm2.voltage = c.calculate(a2.getState(), goal, t)
+		a2.arm1.update()
+		a2.arm2.update()
+		a2.update()
+		r.record(t+dt, arm.angle, arm.voltage, arm.current)
+	} //loop
+
+	return r
+} //end simulateStep
+
+//Find the ultimate gain and period of a joint with a relay (Astrom-Hagglund)
+//int joint - joint to tune, 1 or 2
+//ArmState setpoint - state to oscillate about
+//float64 d - relay amplitude in Volts
+//float64 hysteresis - error the relay waits for before switching in radians
+//return - ultimate gain in Volts/radian and ultimate period in seconds
+func relayTune(joint int, setpoint ArmState, d, hysteresis float64) (float64, float64, error) {
+	a2 := makeArm2()
+	a2.arm1.angle, a2.arm2.angle = setpoint[0], setpoint[2]
+	a2.update()
+
+	arm, target := a2.arm1, setpoint[0]
+	if joint == 2 {
+		arm, target = a2.arm2, setpoint[2]
+	} //if
+
+	//hold the other joint with PID while the relay drives this one about the setpoint
+	c := &pidFFController{arm2: a2}
+	relay := d
+	var times, errors []float64
+	for t := 0.0; t < 10; t += dt {
+		v1, v2 := c.calculate(a2.getState(), setpoint, t)
+		ff1, ff2 := calcFFArm2(a2, 0, 0, 0, 0)
+		//switch only once the error leaves the hysteresis band
+		e := target - arm.angle
+		if e > hysteresis {
+			relay = d
+		} else if e < -hysteresis {
+			relay = -d
+		} //if
+		if joint == 1 {
+			v1 = ff1 + relay
+		} else {
+			v2 = ff2 + relay
+		} //if
+
+		a2.arm1.voltage, a2.arm2.voltage = v1, v2
+		a2.arm1.update()
+		a2.arm2.update()
+		a2.update()
+		times = append(times, t+dt)
+		errors = append(errors, target-arm.angle)
+	} //loop
+
+	amplitude, period := measureOscillation(times, errors)
+	if amplitude <= hysteresis || period == 0 {
+		return 0, 0, fmt.Errorf("relay did not make the joint oscillate")
+	} //if
+
+	//describing function of a relay with hysteresis
+	return (4 * d) / (math.Pi * math.Sqrt(amplitude*amplitude-hysteresis*hysteresis)), period, nil
+} //end relayTune
+
+//Measure the amplitude and period of an oscillating signal about zero
+//[]float64 times - time of each sample
+//[]float64 values - signal at each sample
+//return - amplitude and period, zero if it does not oscillate
+func measureOscillation(times, values []float64) (float64, float64) {
+	//times the signal crosses zero going up
+	var crossings []int
+	for i := 1; i < len(values); i++ {
+		if values[i-1] < 0 && values[i] >= 0 {
+			crossings = append(crossings, i)
+		} //if
+	} //loop
+	if len(crossings) < 3 {
+		return 0, 0
+	} //if
+
+	//use the last two full cycles once it has settled into the oscillation
+	first, last := crossings[len(crossings)-3], crossings[len(crossings)-1]
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, v := range values[first:last] {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	} //loop
+
+	return (max - min) / 2, (times[last] - times[first]) / 2
+} //end measureOscillation
+
+//Calculate PD gains from the ultimate gain and period, with the no-overshoot Ziegler-Nichols kP and the classic Td
+//the feedforward holds the arm up in place of an integral, which would only wind up during long moves
+//float64 ku - ultimate gain in Volts/radian
+//float64 tu - ultimate period in seconds
+//return - gains in the units of pidcontroller
+func znGains(ku, tu float64) pidcontroller {
+	kP := 0.2 * ku      //a third of the classic 0.6Ku
+	kD := kP * (tu / 8) //Td = Tu/8
+
+	//pidcontroller outputs a fraction of the max voltage and uses per-tick differences
+	return pidcontroller{kP: kP / MaxVoltage, kD: kD / dt / MaxVoltage}
+} //end znGains
+
+//Calculate the cost of a step response for the optimizer
+//stepMetrics m - metrics of the response
+//return - cost where 1 second of settling = 10% overshoot = 1000 V^2s of effort
+func tuneCost(m stepMetrics) float64 {
+	settle := m.settleTime
+	if math.IsNaN(settle) { //never settled
+		settle = 2 * tuneDuration
+	} //if
+	return settle + m.overshoot/10 + m.effort/1000
+} //end tuneCost
+
+//Tune the gains of a joint with Nelder-Mead
+//int joint - joint to tune, 1 or 2
+//pidcontroller initial - gains to start from
+//ArmState start - state both joints start at
+//float64 target - angle to step the joint to in radians
+//return - the tuned gains
+func optimizeGains(joint int, initial pidcontroller, start ArmState, target float64) pidcontroller {
+	cost := func(x []float64) float64 {
+		if x[0] < 0 || x[1] < 0 || x[2] < 0 { //no negative gains
+			return math.Inf(1)
+		} //if
+		return tuneCost(calcStepMetrics(simulateStep(joint, pidcontroller{kP: x[0], kI: x[1], kD: x[2]}, start, target)))
+	} //end cost
+
+	x0 := []float64{initial.kP, initial.kI, initial.kD}
+	steps := []float64{math.Max(initial.kP*0.5, 0.5), math.Max(initial.kI*0.5, 0.001), math.Max(initial.kD*0.5, 0.01)}
+	best, _ := nelderMead(cost, x0, steps, 200)
+
+	return pidcontroller{kP: best[0], kI: best[1], kD: best[2]}
+} //end optimizeGains
+
+//Minimize a function with the Nelder-Mead simplex method
+//func f - function to minimize
+//[]float64 x0 - starting point
+//[]float64 steps - size of the starting simplex along each axis
+//int iterations - maximum number of iterations
+//return - the best point and its value
+func nelderMead(f func([]float64) float64, x0, steps []float64, iterations int) ([]float64, float64) {
+	n := len(x0)
+	type vertex struct {
+		x []float64
+		f float64
+	}
+
+	//starting simplex around x0
+	simplex := make([]vertex, n+1)
+	simplex[0] = vertex{append([]float64(nil), x0...), f(x0)}
+	for i := 0; i < n; i++ {
+		x := append([]float64(nil), x0...)
+		x[i] += steps[i]
+		simplex[i+1] = vertex{x, f(x)}
+	} //loop
+
+	//point along the line from the centroid through the worst vertex
+	along := func(centroid, worst []float64, k float64) []float64 {
+		x := make([]float64, n)
+		for i := range x {
+			x[i] = centroid[i] + k*(worst[i]-centroid[i])
+		} //loop
+		return x
+	} //end along
+
+	for it := 0; it < iterations; it++ {
+		sort.Slice(simplex, func(i, j int) bool { return simplex[i].f < simplex[j].f })
+		if math.Abs(simplex[n].f-simplex[0].f) < 1e-9 { //converged
+			break
+		} //if
+
+		centroid := make([]float64, n)
+		for _, v := range simplex[:n] {
+			for i := range centroid {
+				centroid[i] += v.x[i] / float64(n)
+			} //loop
+		} //loop
+
+		worst := simplex[n]
+		reflected := along(centroid, worst.x, -1)
+		fr := f(reflected)
+		switch {
+		case fr < simplex[0].f: //try going further
+			expanded := along(centroid, worst.x, -2)
+			if fe := f(expanded); fe < fr {
+				simplex[n] = vertex{expanded, fe}
+			} else {
+				simplex[n] = vertex{reflected, fr}
+			} //if
+		case fr < simplex[n-1].f:
+			simplex[n] = vertex{reflected, fr}
+		default: //contract towards the centroid, or shrink towards the best
+			contracted := along(centroid, worst.x, 0.5)
+			if fc := f(contracted); fc < worst.f {
+				simplex[n] = vertex{contracted, fc}
+			} else {
+				for i := 1; i <= n; i++ {
+					simplex[i].x = along(simplex[0].x, simplex[i].x, 0.5)
+					simplex[i].f = f(simplex[i].x)
+				} //loop
+			} //if
+		} //switch
+	} //loop
+
+	sort.Slice(simplex, func(i, j int) bool { return simplex[i].f < simplex[j].f })
+	return simplex[0].x, simplex[0].f
+} //end nelderMead
+
+//Tune a joint and print the gains and their step response
+//int joint - joint to tune, 1 or 2
+//string method - relay or nm
+func runTuning(joint int, method string) {
+	if joint != 1 && joint != 2 {
+		fmt.Println("no joint", joint, "to tune - use 1 or 2")
+		return
+	} //if
+	setpoint := ArmState{ToRadians(30), 0, ToRadians(30), 0}
+	target := setpoint[joint*2-2] + ToRadians(30)
+	a2 := makeArm2()
+	gains := a2.arm1.pid
+	if joint == 2 {
+		gains = a2.arm2.pid
+	} //if
+
+	switch method {
+	case "relay":
+		ku, tu, err := relayTune(joint, setpoint, 2, ToRadians(1))
+		if err != nil {
+			fmt.Println("tuning failed:", err)
+			return
+		} //if
+		fmt.Printf("ultimate gain %.2f V/rad, ultimate period %.3f s\n", ku, tu)
+		gains = znGains(ku, tu)
+	case "nm":
+		gains = optimizeGains(joint, gains, setpoint, target)
+	default:
+		fmt.Println("unknown tuning method", method, "- use relay or nm")
+		return
+	} //switch
+
+	m := calcStepMetrics(simulateStep(joint, gains, setpoint, target))
+	fmt.Printf("joint %d tuned with %s: kP %.4f kI %.4f kD %.4f\n", joint, method, gains.kP, gains.kI, gains.kD)
+	fmt.Println(m)
+} //end runTuning
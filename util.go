@@ -8,6 +8,8 @@ package main
 import (
 	"github.com/faiface/pixel"
 	"math"
+	"strconv"
+	"strings"
 )
 
 //Constants
@@ -82,19 +84,13 @@ func cosLawAngle(a, b, c float64) float64 {
 	return math.Acos((a*a + b*b - c*c) / (2 * a * b))
 } //end cosLawAngle
 
-//Check if two points are within the bounds of one another
+//WithinBounds checks if a point is within a circle around another
 //Point target - target to be within
 //Point current - current (x,y) coordinate
 //tolerance float64 - radius of the circle around the target you can be in
-func withinBounds(target, current Point, tolerance float64) bool {
-	xDiff := math.Abs(target.x - current.x)
-	yDiff := math.Abs(target.y - current.y)
-
-	if xDiff < tolerance && yDiff < tolerance {
-		return true
-	}
-	return false
-} //end withinBounds
+func WithinBounds(target, current Point, tolerance float64) bool {
+	return math.Hypot(target.x-current.x, target.y-current.y) <= tolerance
+} //end WithinBounds
 
 //Scale a point by a value
 //Point p - point to scale
@@ -137,3 +133,21 @@ func ClampToCSpace(p Point, l1, l2 float64) Point {
 
 	return Point{r * math.Cos(theta), r * math.Sin(theta)}
 } //end clampToCSpace
+
+//ParseFloats parses a comma-separated list of numbers
+//string s - list of numbers such as "1,2.5,3"
+//return - the numbers, or an error if one is not a number
+func ParseFloats(s string) ([]float64, error) {
+	fields := strings.Split(s, ",")
+	nums := make([]float64, len(fields))
+
+	for i, f := range fields {
+		n, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return nil, err
+		} //if
+		nums[i] = n
+	} //loop
+
+	return nums, nil
+} //end ParseFloats
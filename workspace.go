@@ -0,0 +1,172 @@
+//workspace
+//Created on: 10/19/2026
+//Region the end of the arm can actually reach with its joint limits and the obstacles around it
+
+package main
+
+import (
+	"math"
+)
+
+//obstacle is something in the environment the arm can't pass through
+type obstacle interface {
+	//check if a point is inside the obstacle
+	//Point p - point to check in meters
+	contains(p Point) bool
+} //end interface
+
+//floorObstacle is the ground, blocking everything below a height
+type floorObstacle struct {
+	height float64 //height of the floor in meters
+} //end struct
+
+//Check if a point is below the floor
+//Point p - point to check in meters
+func (f floorObstacle) contains(p Point) bool {
+	return p.y < f.height-1e-9
+} //end contains
+
+//circleObstacle is a round object in the plane of the arm
+type circleObstacle struct {
+	center Point   //center of the circle in meters
+	radius float64 //radius of the circle in meters
+} //end struct
+
+//Check if a point is inside the circle
+//Point p - point to check in meters
+func (c circleObstacle) contains(p Point) bool {
+	return math.Hypot(p.x-c.center.x, p.y-c.center.y) < c.radius
+} //end contains
+
+//number of points checked along each link for collisions
+const collisionSamples = 10
+
+//Check if the arm hits any obstacle at some joint angles
+//float64 q1 - angle of the first joint
+//float64 q2 - angle of the second joint relative to the first
+//[]obstacle obstacles - obstacles to check against
+//return - whether any part of the arm is inside an obstacle
+func (a2 Arm2) collides(q1, q2 float64, obstacles []obstacle) bool {
+	points := a2.chainAt(q1, q2).jointPoints()
+	for i := 1; i < len(points); i++ {
+		start, end := points[i-1], points[i]
+		for j := 1; j <= collisionSamples; j++ { //along the link, not including where it starts
+			u := float64(j) / collisionSamples
+			p := Point{start.x + u*(end.x-start.x), start.y + u*(end.y-start.y)}
+			for _, o := range obstacles {
+				if o.contains(p) {
+					return true
+				} //if
+			} //loop
+		} //loop
+	} //loop
+	return false
+} //end collides
+
+//Check if the arm can hold some joint angles, within its limits and clear of obstacles
+//float64 q1 - angle of the first joint
+//float64 q2 - angle of the second joint relative to the first
+//[]obstacle obstacles - obstacles to check against
+func (a2 Arm2) isValidPose(q1, q2 float64, obstacles []obstacle) bool {
+	return a2.arm1.withinLimits(q1) && a2.arm2.withinLimits(q2) && !a2.collides(q1, q2, obstacles)
+} //end isValidPose
+
+//cellKey is the index of a cell in the workspace grid
+type cellKey [2]int
+
+//workspace is the region the end of the arm can reach, sampled over the joint space into a grid of cells
+type workspace struct {
+	arm2      Arm2              //arm reaching through the workspace
+	obstacles []obstacle        //obstacles the arm can't pass through
+	cellSize  float64           //width and height of each cell in meters
+	cells     map[cellKey]Point //reachable cells and an end point inside each
+} //end struct
+
+//Create the workspace of an arm by sampling its joint angles
+//Arm2 a2 - arm to find the workspace of
+//[]obstacle obstacles - obstacles the arm can't pass through
+//float64 step - spacing of the sampled joint angles in radians
+//float64 cellSize - width and height of each cell in meters
+func newWorkspace(a2 Arm2, obstacles []obstacle, step, cellSize float64) *workspace {
+	ws := &workspace{arm2: a2, obstacles: obstacles, cellSize: cellSize, cells: map[cellKey]Point{}}
+
+	min1, max1 := jointRange(*a2.arm1)
+	min2, max2 := jointRange(*a2.arm2)
+	for q1 := min1; q1 <= max1; q1 += step {
+		for q2 := min2; q2 <= max2; q2 += step {
+			if !a2.isValidPose(q1, q2, obstacles) {
+				continue
+			} //if
+
+			//keep the end point closest to the middle of its cell
+			tip := a2.calcEndPoint(q1, q2)
+			key := ws.cellOf(tip)
+			if old, ok := ws.cells[key]; !ok || ws.distanceToCenter(tip, key) < ws.distanceToCenter(old, key) {
+				ws.cells[key] = tip
+			} //if
+		} //loop
+	} //loop
+
+	return ws
+} //end newWorkspace
+
+//Get the range of angles a joint can sweep through
+//Arm a - joint to get the range of
+//return - lowest and highest angles in radians, a whole turn for a joint without limits
+func jointRange(a Arm) (float64, float64) {
+	if a.limited {
+		return a.minAngle, a.maxAngle
+	} //if
+	return -math.Pi, math.Pi
+} //end jointRange
+
+//Find the cell a point is in
+//Point p - point in meters
+func (ws workspace) cellOf(p Point) cellKey {
+	return cellKey{int(math.Floor(p.x / ws.cellSize)), int(math.Floor(p.y / ws.cellSize))}
+} //end cellOf
+
+//Calculate the distance from a point to the middle of a cell
+//Point p - point in meters
+//cellKey key - cell to measure to
+func (ws workspace) distanceToCenter(p Point, key cellKey) float64 {
+	return math.Hypot(p.x-(float64(key[0])+0.5)*ws.cellSize, p.y-(float64(key[1])+0.5)*ws.cellSize)
+} //end distanceToCenter
+
+//Check if the end of the arm can reach a point exactly
+//Point p - point to check in meters
+//return - whether a solution reaches it within the limits and clear of the obstacles
+func (ws workspace) reaches(p Point) bool {
+	if checkReach(p, ws.arm2.arm1.length, ws.arm2.arm2.length) != reachable {
+		return false
+	} //if
+
+	for _, s := range InverseKinematicsAll(p, ws.arm2.arm1.length, ws.arm2.arm2.length) {
+		//the turn of each angle that lands inside the joint's range
+		min1, _ := jointRange(*ws.arm2.arm1)
+		min2, _ := jointRange(*ws.arm2.arm2)
+		q1 := s.q1 + 2*math.Pi*math.Ceil((min1-s.q1)/(2*math.Pi))
+		q2 := s.q2 + 2*math.Pi*math.Ceil((min2-s.q2)/(2*math.Pi))
+		if ws.arm2.isValidPose(q1, q2, ws.obstacles) {
+			return true
+		} //if
+	} //loop
+	return false
+} //end reaches
+
+//Keep a point within the workspace
+//Point p - point to clamp in meters
+//return - the point if the arm can reach it, otherwise the closest sampled point it can
+func (ws workspace) clamp(p Point) Point {
+	if ws.reaches(p) || len(ws.cells) == 0 {
+		return p
+	} //if
+
+	best, bestDist := p, math.Inf(1)
+	for _, tip := range ws.cells {
+		if d := math.Hypot(tip.x-p.x, tip.y-p.y); d < bestDist {
+			best, bestDist = tip, d
+		} //if
+	} //loop
+	return best
+} //end clamp
@@ -0,0 +1,160 @@
+//wrist
+//Created on: 10/19/2026
+//Goals with an approach angle for the claw, and a wrist joint to hold it
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+//Goal is where the end of the arm should go, and optionally the angle it should get there at
+type Goal struct {
+	point    Point   //end point in meters, the tip of the claw if there is a wrist
+	heading  float64 //angle of the last link from the horizontal in radians, if oriented
+	oriented bool    //whether the heading has to be met as well as the point
+} //end struct
+
+//Create a goal for just a point
+//Point p - end point in meters
+func pointGoal(p Point) Goal {
+	return Goal{point: p}
+} //end pointGoal
+
+//Create a goal for a point and the angle to approach it at
+//Point p - end point in meters
+//float64 heading - angle of the last link from the horizontal in radians
+func orientedGoal(p Point, heading float64) Goal {
+	return Goal{point: p, heading: heading, oriented: true}
+} //end orientedGoal
+
+//get a string representation of the goal
+func (g Goal) String() string {
+	if !g.oriented {
+		return fmt.Sprintf("(%.3f, %.3f)", g.point.x, g.point.y)
+	} //if
+	return fmt.Sprintf("(%.3f, %.3f) at %.1f deg", g.point.x, g.point.y, ToDegrees(g.heading))
+} //end String
+
+//orientFallback is what to give up when a heading can't be met at a point
+type orientFallback int
+
+const (
+	keepHeading orientFallback = iota //hold the heading and get the claw as close to the point as it can
+	keepPoint                         //reach the point at the closest heading that can
+)
+
+//get a string representation of the fallback
+func (f orientFallback) String() string {
+	return [...]string{"heading", "point"}[f]
+} //end String
+
+//Find a fallback by name
+//string name - name of the fallback
+//return - the fallback, or an error if there is none with the name
+func parseOrientFallback(name string) (orientFallback, error) {
+	for f := keepHeading; f <= keepPoint; f++ {
+		if f.String() == name {
+			return f, nil
+		} //if
+	} //loop
+	return keepHeading, fmt.Errorf("no orientation fallback named %q", name)
+} //end parseOrientFallback
+
+//orientationError is a goal the claw can't meet at its heading and what it settled for
+type orientationError struct {
+	goal    Goal //goal that was asked for
+	reached Goal //closest the claw can get, with its heading
+} //end struct
+
+//get a string representation of the error
+func (e *orientationError) Error() string {
+	return fmt.Sprintf("%v can't be reached, using %v", e.goal, e.reached)
+} //end Error
+
+//Add a wrist joint holding a claw to the end of the arm
+//float64 length - length of the claw in meters
+//float64 mass - mass of the wrist and claw in kg
+func (a2 *Arm2) addWrist(length, mass float64) {
+	a2.arm3 = NewArm(length, mass, 15, 1, 0.5, 0.0, 0.0, "cim", 0)
+	a2.arm3.isSecondJoint = true
+	a2.arm3.ff = motorFFGains(a2.arm3, 0)
+	a2.payload += mass //the elbow carries the wrist like a payload
+	a2.update()
+} //end addWrist
+
+//Calculate the heading of the claw, or of the second link without a wrist
+//return - angle of the last link from the horizontal in radians
+func (a2 Arm2) calcHeading() float64 {
+	heading := a2.arm1.angle + a2.arm2.angle
+	if a2.arm3 != nil {
+		heading += a2.arm3.angle
+	} //if
+	return heading
+} //end calcHeading
+
+//Calculate the tip of the claw from all three joint angles
+//float64 q1 - angle of the first joint
+//float64 q2 - angle of the second joint relative to the first
+//float64 q3 - angle of the wrist relative to the second joint
+//return - tip of the claw in meters
+func (a2 Arm2) calcClawPoint(q1, q2, q3 float64) Point {
+	w := a2.calcEndPoint(q1, q2)
+	return Point{w.x + a2.arm3.length*math.Cos(q1+q2+q3), w.y + a2.arm3.length*math.Sin(q1+q2+q3)}
+} //end calcClawPoint
+
+//Solve for a goal with all three joints, the shoulder and elbow placing the wrist so the claw meets the heading
+//Goal goal - where the claw should go, keeping its current heading if the goal has none
+//ikPolicy policy - how to pick between the shoulder and elbow solutions
+//orientFallback fallback - what to give up if the heading can't be met at the point
+//return - the shoulder and elbow angles, the wrist angle, and an error if the goal can't be met
+func (a2 Arm2) solveWristIK(goal Goal, policy ikPolicy, fallback orientFallback) (ikSolution, float64, error) {
+	heading := a2.calcHeading()
+	if goal.oriented {
+		heading = goal.heading
+	} //if
+
+	//step the wrist back from the point along the heading, and turn it to make up the rest of the heading
+	solveAt := func(heading float64) (ikSolution, float64, error) {
+		wrist := Point{goal.point.x - a2.arm3.length*math.Cos(heading), goal.point.y - a2.arm3.length*math.Sin(heading)}
+		s, err := a2.solveIK(wrist, policy)
+		q3 := nearestTurn(heading-s.q1-s.q2, a2.arm3.angle)
+		if !a2.arm3.withinLimits(q3) && err == nil {
+			err = &ikError{reach: outsideLimits, goal: wrist, nearest: wrist, fallback: s}
+		} //if
+		if ikErr, ok := err.(*ikError); ok && ikErr.reach == singular { //reachable, but only just
+			err = nil
+		} //if
+		return s, a2.arm3.clampToLimits(q3), err
+	} //end solveAt
+
+	s, q3, err := solveAt(heading)
+	if err == nil {
+		return s, q3, nil
+	} //if
+
+	//the closest heading either way that reaches the point
+	for step := 1; step <= 180; step++ {
+		for _, side := range []float64{1, -1} {
+			h := heading + side*ToRadians(float64(step))
+			hs, hq3, herr := solveAt(h)
+			if herr != nil {
+				continue
+			} //if
+			if fallback == keepPoint {
+				return hs, hq3, &orientationError{goal: orientedGoal(goal.point, heading), reached: orientedGoal(goal.point, h)}
+			} //if
+
+			//hold the heading as close to the point as the arm gets
+			tip := a2.calcClawPoint(s.q1, s.q2, q3)
+			return s, q3, &orientationError{goal: orientedGoal(goal.point, heading), reached: orientedGoal(tip, s.q1+s.q2+q3)}
+		} //loop
+	} //loop
+
+	//no heading reaches the point, so the claw can't get there at all
+	if ikErr, ok := err.(*ikError); ok {
+		err = &ikError{reach: ikErr.reach, goal: goal.point, nearest: a2.calcClawPoint(s.q1, s.q2, q3), fallback: s}
+	} //if
+	return s, q3, err
+} //end solveWristIK